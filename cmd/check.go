@@ -0,0 +1,76 @@
+/*
+Copyright © 2022 Lars Wegmann
+
+*/
+package cmd
+
+import (
+	"git.larswegmann.de/lars/impose/composeparser"
+	"git.larswegmann.de/lars/impose/registry"
+	"github.com/spf13/cobra"
+)
+
+const (
+	exitUpToDate         = 0
+	exitError            = 1
+	exitUpdatesAvailable = 2
+	exitWarnings         = 3
+)
+
+type checker interface {
+	HasUpdates() bool
+	HasWarnings() bool
+}
+
+// checkCmd represents the check command
+var checkCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Check for image version updates",
+	Long: `Checks the image versions in the specified Docker Compose file for updates
+without changing the file.
+
+The exit code reflects the result of the check:
+  0  all images are up to date
+  1  an error occurred (e.g. the version lookup of a service failed)
+  2  updates are available
+  3  updates are available that trigger a warning annotation
+     (impose:warnMajor, impose:warnMinor, impose:warnPatch or impose:warnAll)`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		parser := composeparser.NewGroup()
+		err := addInputFiles(cmd, parser)
+		if err != nil {
+			return err
+		}
+		r := registry.NewCache(registry.NewRouter(regCfg))
+		err = parser.UpdateVersions(r)
+		if err != nil {
+			return err
+		}
+		err = writeReport(parser)
+		if err != nil {
+			return err
+		}
+		err = failureError(parser)
+		if err != nil {
+			return err
+		}
+		exitCode = checkExitCode(parser)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(checkCmd)
+	addLookupFlags(checkCmd)
+	addReportFlags(checkCmd)
+}
+
+func checkExitCode(c checker) int {
+	if c.HasWarnings() {
+		return exitWarnings
+	}
+	if c.HasUpdates() {
+		return exitUpdatesAvailable
+	}
+	return exitUpToDate
+}
@@ -0,0 +1,48 @@
+package cmd
+
+import "testing"
+
+type checkerMock struct {
+	hasUpdates  bool
+	hasWarnings bool
+}
+
+func (c *checkerMock) HasUpdates() bool {
+	return c.hasUpdates
+}
+
+func (c *checkerMock) HasWarnings() bool {
+	return c.hasWarnings
+}
+
+func TestCheckExitCode(t *testing.T) {
+	tests := []struct {
+		name     string
+		checker  *checkerMock
+		expected int
+	}{
+		{
+			"up to date",
+			&checkerMock{},
+			exitUpToDate,
+		},
+		{
+			"updates available",
+			&checkerMock{hasUpdates: true},
+			exitUpdatesAvailable,
+		},
+		{
+			"updates with warnings",
+			&checkerMock{hasUpdates: true, hasWarnings: true},
+			exitWarnings,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual := checkExitCode(tt.checker)
+			if tt.expected != actual {
+				t.Errorf("expected exit code %d, got %d", tt.expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+/*
+Copyright © 2022 Lars Wegmann
+
+*/
+package cmd
+
+import (
+	"path/filepath"
+
+	"git.larswegmann.de/lars/impose/config"
+	"github.com/spf13/cobra"
+)
+
+// configLoader loads the config files of the input files. Each config file is
+// only loaded once. The registry settings of the first config file are
+// applied, as all files are looked up with the same registries. Registry
+// flags that are set explicitly take precedence over the config file.
+type configLoader struct {
+	cmd             *cobra.Command
+	configs         map[string]*config.Config
+	registryApplied bool
+}
+
+// load returns the config file given by '--config' or the one found next to
+// the input file, or nil if there is none.
+func (l *configLoader) load(input string) (*config.Config, error) {
+	file := opts.ConfigFile
+	if file == "" {
+		file = config.Find(filepath.Dir(input))
+		if file == "" {
+			return nil, nil
+		}
+	}
+	if cfg, ok := l.configs[file]; ok {
+		return cfg, nil
+	}
+	cfg, err := config.Load(file)
+	if err != nil {
+		return nil, err
+	}
+	if l.configs == nil {
+		l.configs = map[string]*config.Config{}
+	}
+	l.configs[file] = cfg
+	if !l.registryApplied {
+		applyRegistryConfig(l.cmd, &cfg.Registry)
+		l.registryApplied = true
+	}
+	return cfg, nil
+}
+
+func applyRegistryConfig(cmd *cobra.Command, r *config.Registry) {
+	flags := cmd.Flags()
+	if r.URL != "" && !flags.Changed("registry") {
+		regCfg.Registry = r.URL
+	}
+	if r.User != "" && !flags.Changed("user") {
+		regCfg.User = r.User
+	}
+	if r.Password != "" && !flags.Changed("password") {
+		regCfg.Password = r.Password
+	}
+	if r.DockerConfig != "" && !flags.Changed("docker-config") {
+		regCfg.DockerConfig = r.DockerConfig
+	}
+	if r.MaxPages != nil && !flags.Changed("max-pages") {
+		regCfg.MaxPages = *r.MaxPages
+	}
+}
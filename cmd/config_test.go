@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"git.larswegmann.de/lars/impose/registry"
+)
+
+func TestConfigLoader(t *testing.T) {
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, ".impose.yml"), []byte(`defaults:
+  update: minor
+registry:
+  url: https://registry.example.com
+  user: config-user
+  maxPages: 10
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	origRegCfg, origOpts := *regCfg, *opts
+	defer func() {
+		*regCfg, *opts = origRegCfg, origOpts
+	}()
+	*regCfg = registry.Config{Registry: "https://hub.docker.com", MaxPages: 50}
+	opts.ConfigFile = ""
+
+	err = updateCmd.Flags().Set("user", "flag-user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := &configLoader{cmd: updateCmd}
+	cfg, err := l.load(filepath.Join(dir, "docker-compose.yml"))
+	if err != nil {
+		t.Fatalf("expected no error, got '%v'", err)
+	}
+	if cfg == nil || cfg.Defaults.Update != "minor" {
+		t.Fatal("expected the config next to the input file")
+	}
+	expected := registry.Config{
+		Registry: "https://registry.example.com",
+		User:     "flag-user",
+		MaxPages: 10,
+	}
+	if *regCfg != expected {
+		t.Errorf("expected '%+v', got '%+v'", expected, *regCfg)
+	}
+
+	again, err := l.load(filepath.Join(dir, "docker-compose.override.yml"))
+	if err != nil {
+		t.Fatalf("expected no error, got '%v'", err)
+	}
+	if again != cfg {
+		t.Error("expected the config file to be loaded only once")
+	}
+}
+
+func TestConfigLoader_noConfigFile(t *testing.T) {
+	origOpts := *opts
+	defer func() { *opts = origOpts }()
+	opts.ConfigFile = ""
+	l := &configLoader{cmd: checkCmd}
+	cfg, err := l.load(filepath.Join(t.TempDir(), "docker-compose.yml"))
+	if err != nil {
+		t.Fatalf("expected no error, got '%v'", err)
+	}
+	if cfg != nil {
+		t.Error("expected no config")
+	}
+}
+
+func TestConfigLoader_missingConfigFile(t *testing.T) {
+	origOpts := *opts
+	defer func() { *opts = origOpts }()
+	opts.ConfigFile = filepath.Join(t.TempDir(), "impose.yml")
+	l := &configLoader{cmd: checkCmd}
+	_, err := l.load("docker-compose.yml")
+	if err == nil {
+		t.Error("expected error, got nil")
+	}
+}
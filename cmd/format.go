@@ -13,11 +13,13 @@ import (
 var formatCmd = &cobra.Command{
 	Use:   "format",
 	Short: "Formats the Docker Compose file",
-	Long: `Formats the Docker Compose file the same way an update would format it
-without otherwise changing the content. This can be useful for taking a diff
-(first format the file, then update the versions).`,
+	Long: `Formats the Docker Compose file with the indentation and quoting of the
+YAML encoder without otherwise changing the content. Updates keep the format
+of the file and only replace the changed images.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		parser, err := composeparser.NewParser(opts.InputFile)
+		parserOpts.Reformat = true
+		parser := composeparser.NewGroup()
+		err := addInputFiles(cmd, parser)
 		if err != nil {
 			return err
 		}
@@ -0,0 +1,57 @@
+/*
+Copyright © 2022 Lars Wegmann
+
+*/
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+type reporter interface {
+	WriteSummary(w io.Writer) error
+	WriteJSONReport(w io.Writer) error
+}
+
+var reportFormat string
+var reportFile string
+
+func addReportFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&reportFormat, "report-format", "text", "Format of the summary (\"text\" or \"json\")")
+	cmd.Flags().StringVar(&reportFile, "report-file", "", "Write the summary to the given file instead of std out")
+}
+
+// writeReport writes the summary in the selected format to std out or the
+// report file. Unless a report file is given, nothing is written in silent mode.
+func writeReport(r reporter) (err error) {
+	if silent && reportFile == "" {
+		return nil
+	}
+	var w io.Writer = os.Stdout
+	if reportFile != "" {
+		f, err := os.Create(reportFile)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			closeErr := f.Close()
+			if err == nil {
+				err = closeErr
+			}
+		}()
+		w = f
+	}
+	switch reportFormat {
+	case "text":
+		err = r.WriteSummary(w)
+	case "json":
+		err = r.WriteJSONReport(w)
+	default:
+		err = fmt.Errorf("unknown report format '%v'", reportFormat)
+	}
+	return
+}
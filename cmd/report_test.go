@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type reporterMock struct{}
+
+func (r *reporterMock) WriteSummary(w io.Writer) error {
+	_, err := io.WriteString(w, "summary")
+	return err
+}
+
+func (r *reporterMock) WriteJSONReport(w io.Writer) error {
+	_, err := io.WriteString(w, "{}")
+	return err
+}
+
+func TestWriteReport_formats(t *testing.T) {
+	tests := []struct {
+		format   string
+		expected string
+	}{
+		{"text", "summary"},
+		{"json", "{}"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			reportFormat = tt.format
+			reportFile = filepath.Join(t.TempDir(), "report")
+			silent = false
+			err := writeReport(&reporterMock{})
+			if err != nil {
+				t.Fatalf("expected no error, got '%v'", err)
+			}
+			b, err := os.ReadFile(reportFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.expected != string(b) {
+				t.Errorf("expected '%v', got '%v'", tt.expected, string(b))
+			}
+		})
+	}
+}
+
+func TestWriteReport_unknownFormat(t *testing.T) {
+	reportFormat = "xml"
+	reportFile = filepath.Join(t.TempDir(), "report")
+	silent = false
+	err := writeReport(&reporterMock{})
+	if err == nil {
+		t.Error("expected error")
+	}
+}
+
+func TestWriteReport_silentWithReportFile(t *testing.T) {
+	reportFormat = "json"
+	reportFile = filepath.Join(t.TempDir(), "report")
+	silent = true
+	defer func() { silent = false }()
+	err := writeReport(&reporterMock{})
+	if err != nil {
+		t.Fatalf("expected no error, got '%v'", err)
+	}
+	if _, err := os.Stat(reportFile); err != nil {
+		t.Errorf("expected report file to be written, got '%v'", err)
+	}
+}
@@ -22,8 +22,14 @@ THE SOFTWARE.
 package cmd
 
 import (
+	"errors"
+	"fmt"
+	"io/fs"
 	"os"
+	"path"
+	"path/filepath"
 
+	"git.larswegmann.de/lars/impose/composeparser"
 	"github.com/spf13/cobra"
 )
 
@@ -36,19 +42,36 @@ This tool automatically scans the given Docker Compose
 file for image versions and updates them.
 
 You can use head or inline comments for the image keyword in the Docker Compose file to add annotations.
+In Dockerfiles the annotations are added as comments directly above the FROM (or ARG) instruction.
 The following annotations are available:
-  impose:ignore     ignores the image for updates
-  impose:minor      only checks for minor version updates
-  impose:patch      only checks for patch version updates
-  impose:warnMajor  warns if major version has changed
-  impose:warnMinor  warns if minor version has changed (including major version changes)
-  impose:warnPatch  warns if patch version has changed (including major and minor version changes)
-  impose:warnAll    warns if the version string has changed in any way (including version suffix)`,
+  impose:ignore             ignores the image for updates
+  impose:major              checks for all version updates (default, overrides the update mode of the config file)
+  impose:minor              only checks for minor version updates
+  impose:patch              only checks for patch version updates
+  impose:warnMajor          warns if major version has changed
+  impose:warnMinor          warns if minor version has changed (including major version changes)
+  impose:warnPatch          warns if patch version has changed (including major and minor version changes)
+  impose:warnAll            warns if the version string has changed in any way (including version suffix)
+  impose:digest             pins the image to the digest of its latest version
+  impose:noPrerelease       never updates to pre-release versions
+  impose:constraint=<range> only updates to versions within the range, e.g. impose:constraint=">=1.4 <2"
+  impose:match=<regex>      only updates to tags matching the regular expression (named groups major, minor and patch set the version)
+  impose:sort=<order>       sorts the tags by semver (default), lexical or date`,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		// the flags and arguments are valid at this point, so errors of the
+		// command (e.g. failed version lookups) are not followed by the usage
+		cmd.SilenceUsage = true
+	},
 }
 
 type CliOptions struct {
-	InputFile  string
+	InputFiles []string
+	Recursive  bool
+	Include    []string
+	Exclude    []string
 	OutputFile string
+	Dockerfile string
+	ConfigFile string
 }
 
 type writer interface {
@@ -59,19 +82,173 @@ type writer interface {
 
 var opts *CliOptions
 
+// exitCode is the exit code of the program if the command did not return an
+// error. It can be set by commands to signal a result (see the check command).
+var exitCode int
+
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
 	err := rootCmd.Execute()
 	if err != nil {
-		os.Exit(1)
+		os.Exit(exitError)
 	}
+	os.Exit(exitCode)
 }
 
 func init() {
 	opts = &CliOptions{}
-	rootCmd.PersistentFlags().StringVarP(&opts.InputFile, "file", "f", "docker-compose.yml", "Compose file")
+	rootCmd.PersistentFlags().StringArrayVarP(&opts.InputFiles, "file", "f", []string{"docker-compose.yml"}, "Compose file (can be repeated, e.g. for override files)")
+	rootCmd.PersistentFlags().BoolVarP(&opts.Recursive, "recursive", "R", false, "Scan the directories given by --file (default is the current directory) recursively for Compose files")
+	rootCmd.PersistentFlags().StringArrayVar(&opts.Include, "include", nil, "Glob for the file names (or paths relative to the scanned directory) to include in a recursive scan, can be repeated (default is \"docker-compose*.yml\", \"docker-compose*.yaml\", \"compose*.yml\" and \"compose*.yaml\")")
+	rootCmd.PersistentFlags().StringArrayVar(&opts.Exclude, "exclude", nil, "Glob for the files and directories (or paths relative to the scanned directory) to exclude from a recursive scan, can be repeated")
+	rootCmd.PersistentFlags().StringVar(&opts.Dockerfile, "dockerfile", "", "Dockerfile to use instead of the Compose file (files named like \"Dockerfile\" are detected automatically)")
 	rootCmd.PersistentFlags().StringVarP(&opts.OutputFile, "out", "o", "", "The output file (default is the input file, if \"-\" is passed it writes to std out)")
+	rootCmd.PersistentFlags().StringVar(&opts.ConfigFile, "config", "", "Config file (default is \".impose.yml\" next to the Compose file, if it exists)")
+	rootCmd.PersistentFlags().StringArrayVar(&parserOpts.EnvFiles, "env-file", nil, "Env file with the variables for image references, can be repeated (default is \".env\" next to the Compose file)")
+	rootCmd.PersistentFlags().StringVar(&parserOpts.LineEndings, "line-endings", composeparser.LineEndingsAuto, "Line endings of the written files: \"auto\" (keeps the line endings of the input file), \"lf\" or \"crlf\"")
+	rootCmd.PersistentFlags().BoolVar(&parserOpts.FollowBuild, "follow-build", false, "Also update the FROM instructions of the Dockerfiles referenced by build sections (they are updated in place, but only if the Compose file is updated in place and not written with --out)")
+}
+
+type fileAdder interface {
+	Add(file string, opts *composeparser.Options) error
+}
+
+// addInputFiles adds all input files together with their parser options to
+// the group. The options of each file contain the config file given by
+// '--config' or the one found next to the file.
+func addInputFiles(cmd *cobra.Command, g fileAdder) error {
+	files, err := inputFiles(cmd)
+	if err != nil {
+		return err
+	}
+	if len(files) > 1 && opts.OutputFile != "" && opts.OutputFile != "-" {
+		return errors.New("an output file can only be given for a single input file")
+	}
+	configs := &configLoader{cmd: cmd}
+	for _, file := range files {
+		o := *parserOpts
+		o.Dockerfile = opts.Dockerfile != ""
+		o.Config, err = configs.load(file)
+		if err != nil {
+			return err
+		}
+		err = g.Add(file, &o)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// inputFiles returns the files to process: the Dockerfile, the Compose files
+// given by '--file' or the Compose files found in a recursive scan.
+func inputFiles(cmd *cobra.Command) ([]string, error) {
+	if opts.Dockerfile != "" {
+		return []string{opts.Dockerfile}, nil
+	}
+	if !opts.Recursive {
+		return uniqueFiles(opts.InputFiles), nil
+	}
+	include := opts.Include
+	if len(include) == 0 {
+		include = defaultIncludes
+	}
+	err := validatePatterns(append(append([]string{}, include...), opts.Exclude...))
+	if err != nil {
+		return nil, err
+	}
+	roots := opts.InputFiles
+	if !cmd.Flags().Changed("file") {
+		roots = []string{"."}
+	}
+	files := []string{}
+	for _, root := range roots {
+		info, err := os.Stat(root)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, root)
+			continue
+		}
+		found, err := findFiles(root, include, opts.Exclude)
+		if err != nil {
+			return nil, err
+		}
+		if len(found) == 0 {
+			return nil, fmt.Errorf("no Compose files found in '%v'", root)
+		}
+		files = append(files, found...)
+	}
+	return uniqueFiles(files), nil
+}
+
+var defaultIncludes = []string{"docker-compose*.yml", "docker-compose*.yaml", "compose*.yml", "compose*.yaml"}
+
+// findFiles returns all files below the root directory whose name or path
+// relative to the root matches an include pattern. Files and directories
+// matching an exclude pattern are skipped.
+func findFiles(root string, include []string, exclude []string) ([]string, error) {
+	files := []string{}
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == root {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		excluded := matchesPattern(exclude, d.Name(), filepath.ToSlash(rel))
+		if d.IsDir() {
+			if excluded || d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !excluded && matchesPattern(include, d.Name(), filepath.ToSlash(rel)) {
+			files = append(files, path)
+		}
+		return nil
+	})
+	return files, err
+}
+
+func matchesPattern(patterns []string, names ...string) bool {
+	for _, pattern := range patterns {
+		for _, name := range names {
+			if ok, _ := path.Match(pattern, name); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func validatePatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid pattern '%v'", pattern)
+		}
+	}
+	return nil
+}
+
+// uniqueFiles removes duplicate files while keeping the order.
+func uniqueFiles(files []string) []string {
+	seen := map[string]bool{}
+	unique := []string{}
+	for _, file := range files {
+		clean := filepath.Clean(file)
+		if !seen[clean] {
+			seen[clean] = true
+			unique = append(unique, file)
+		}
+	}
+	return unique
 }
 
 func writeOutput(w writer) (err error) {
@@ -2,7 +2,12 @@ package cmd
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
 	"testing"
+
+	"git.larswegmann.de/lars/impose/composeparser"
 )
 
 type writerMoc struct {
@@ -39,14 +44,22 @@ func TestRootCmd_opts(t *testing.T) {
 	}
 	fmt.Println(opts.OutputFile)
 	expected := CliOptions{
-		InputFile:  "input.yml",
+		InputFiles: []string{"input.yml"},
 		OutputFile: "output.yml",
 	}
-	if *opts != expected {
+	if !reflect.DeepEqual(*opts, expected) {
 		t.Errorf("expected '%v', got '%v'", expected, *opts)
 	}
 }
 
+func TestRootCmd_silenceUsage(t *testing.T) {
+	defer func() { checkCmd.SilenceUsage = false }()
+	rootCmd.PersistentPreRun(checkCmd, nil)
+	if !checkCmd.SilenceUsage {
+		t.Error("expected the usage to be silenced for errors of the command")
+	}
+}
+
 func TestRootCmd_writeToOriginalFile(t *testing.T) {
 	expectedFuncCalled := false
 	w := &writerMoc{
@@ -91,3 +104,152 @@ func TestRootCmd_writeToFile(t *testing.T) {
 		t.Error("expected 'WriteToFile' to be called")
 	}
 }
+
+type fileAdderMock struct {
+	files []string
+	opts  []*composeparser.Options
+}
+
+func (f *fileAdderMock) Add(file string, opts *composeparser.Options) error {
+	f.files = append(f.files, file)
+	f.opts = append(f.opts, opts)
+	return nil
+}
+
+func TestAddInputFiles(t *testing.T) {
+	origOpts := *opts
+	defer func() { *opts = origOpts }()
+	opts.InputFiles = []string{"docker-compose.yml", "docker-compose.override.yml", "./docker-compose.yml"}
+	opts.Recursive = false
+	opts.Dockerfile = ""
+	opts.OutputFile = ""
+	opts.ConfigFile = ""
+
+	f := &fileAdderMock{}
+	err := addInputFiles(formatCmd, f)
+	if err != nil {
+		t.Fatalf("expected no error, got '%v'", err)
+	}
+	expected := []string{"docker-compose.yml", "docker-compose.override.yml"}
+	if !reflect.DeepEqual(f.files, expected) {
+		t.Errorf("expected '%v', got '%v'", expected, f.files)
+	}
+
+	opts.Dockerfile = "Dockerfile.prod"
+	f = &fileAdderMock{}
+	err = addInputFiles(formatCmd, f)
+	if err != nil {
+		t.Fatalf("expected no error, got '%v'", err)
+	}
+	if !reflect.DeepEqual(f.files, []string{"Dockerfile.prod"}) || !f.opts[0].Dockerfile {
+		t.Errorf("expected Dockerfile, got '%v'", f.files)
+	}
+	if parserOpts.Dockerfile {
+		t.Error("expected the global parser options not to be changed")
+	}
+}
+
+func TestAddInputFiles_outputFile(t *testing.T) {
+	origOpts := *opts
+	defer func() { *opts = origOpts }()
+	opts.InputFiles = []string{"docker-compose.yml", "docker-compose.override.yml"}
+	opts.Recursive = false
+	opts.Dockerfile = ""
+	opts.OutputFile = "out.yml"
+	err := addInputFiles(formatCmd, &fileAdderMock{})
+	if err == nil {
+		t.Error("expected error, got nil")
+	}
+	opts.OutputFile = "-"
+	err = addInputFiles(formatCmd, &fileAdderMock{})
+	if err != nil {
+		t.Errorf("expected no error, got '%v'", err)
+	}
+}
+
+func TestFindFiles(t *testing.T) {
+	dir := t.TempDir()
+	files := []string{
+		"docker-compose.yml",
+		"docker-compose.override.yml",
+		"README.md",
+		"web/compose.yaml",
+		"web/docker-compose.prod.yml",
+		"legacy/docker-compose.yml",
+		"node_modules/pkg/docker-compose.yml",
+		".git/docker-compose.yml",
+	}
+	for _, file := range files {
+		file = filepath.Join(dir, filepath.FromSlash(file))
+		err := os.MkdirAll(filepath.Dir(file), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.WriteFile(file, []byte{}, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		include  []string
+		exclude  []string
+		expected []string
+	}{
+		{
+			name:    "default includes",
+			include: defaultIncludes,
+			exclude: []string{"node_modules", "legacy/*"},
+			expected: []string{
+				"docker-compose.override.yml",
+				"docker-compose.yml",
+				"web/compose.yaml",
+				"web/docker-compose.prod.yml",
+			},
+		},
+		{
+			name:    "custom includes",
+			include: []string{"docker-compose.yml"},
+			exclude: []string{"node_modules"},
+			expected: []string{
+				"docker-compose.yml",
+				"legacy/docker-compose.yml",
+			},
+		},
+		{
+			name:    "exclude file name",
+			include: defaultIncludes,
+			exclude: []string{"*.override.yml", "docker-compose.prod.yml", "node_modules", "legacy"},
+			expected: []string{
+				"docker-compose.yml",
+				"web/compose.yaml",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			found, err := findFiles(dir, tt.include, tt.exclude)
+			if err != nil {
+				t.Fatalf("expected no error, got '%v'", err)
+			}
+			actual := []string{}
+			for _, file := range found {
+				rel, _ := filepath.Rel(dir, file)
+				actual = append(actual, filepath.ToSlash(rel))
+			}
+			if !reflect.DeepEqual(actual, tt.expected) {
+				t.Errorf("expected '%v', got '%v'", tt.expected, actual)
+			}
+		})
+	}
+}
+
+func TestValidatePatterns(t *testing.T) {
+	if err := validatePatterns([]string{"*.yml", "web/*"}); err != nil {
+		t.Errorf("expected no error, got '%v'", err)
+	}
+	if err := validatePatterns([]string{"[a-"}); err == nil {
+		t.Error("expected error, got nil")
+	}
+}
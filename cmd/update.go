@@ -5,6 +5,7 @@ Copyright © 2022 Lars Wegmann
 package cmd
 
 import (
+	"errors"
 	"fmt"
 
 	"git.larswegmann.de/lars/impose/composeparser"
@@ -12,7 +13,8 @@ import (
 	"github.com/spf13/cobra"
 )
 
-var regCfg *registry.Config
+var regCfg = &registry.Config{}
+var parserOpts = &composeparser.Options{}
 var silent bool
 
 // updateCmd represents the update command
@@ -21,31 +23,61 @@ var updateCmd = &cobra.Command{
 	Short: "Update image versions",
 	Long:  `Updates the image versions in the specified Docker Compose file`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		parser, err := composeparser.NewParser(opts.InputFile)
+		parser := composeparser.NewGroup()
+		err := addInputFiles(cmd, parser)
 		if err != nil {
 			return err
 		}
-		r := registry.NewRegistry(regCfg)
+		r := registry.NewCache(registry.NewRouter(regCfg))
 		err = parser.UpdateVersions(r)
 		if err != nil {
 			return err
 		}
-		if !silent {
-			parser.PrintSummary()
-			if opts.OutputFile == "-" {
-				fmt.Println()
-			}
+		err = writeReport(parser)
+		if err != nil {
+			return err
+		}
+		if !silent && reportFile == "" && opts.OutputFile == "-" {
+			fmt.Println()
+		}
+		err = writeOutput(parser)
+		if err != nil {
+			return err
 		}
-		return writeOutput(parser)
+		return failureError(parser)
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(updateCmd)
+	addLookupFlags(updateCmd)
+	addReportFlags(updateCmd)
+}
+
+// addLookupFlags adds the flags for the version lookup, which are shared by
+// all commands that query the registries.
+func addLookupFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&regCfg.Registry, "registry", "r", "https://hub.docker.com", "Docker registry to use for version lookup of images without registry host")
+	cmd.Flags().StringVarP(&regCfg.User, "user", "u", "", "Docker registry user")
+	cmd.Flags().StringVarP(&regCfg.Password, "password", "p", "", "Docker registry password")
+	cmd.Flags().StringVar(&regCfg.DockerConfig, "docker-config", "", "Docker CLI config file to read registry credentials from (default is \"~/.docker/config.json\")")
+	cmd.Flags().IntVar(&regCfg.MaxPages, "max-pages", 50, "Maximum number of tag list pages to request per image (0 means no limit)")
+	cmd.Flags().BoolVar(&parserOpts.Digest, "digest", false, "Pin all images to the digest of their latest version (e.g. \"alpine:3.16.3@sha256:...\")")
+	cmd.Flags().BoolVar(&parserOpts.NoPrerelease, "no-prerelease", false, "Never update to pre-release versions (e.g. \"1.2.3-rc.1\")")
+	cmd.Flags().BoolVar(&parserOpts.FailFast, "fail-fast", false, "Abort on the first failed version lookup instead of updating the remaining services")
+	cmd.Flags().BoolVarP(&silent, "silent", "s", false, "Do not print summary (a report file is written anyway)")
+}
+
+type failureReporter interface {
+	HasFailures() bool
+}
 
-	regCfg = &registry.Config{}
-	updateCmd.Flags().StringVarP(&regCfg.Registry, "registry", "r", "https://hub.docker.com", "Docker registry to use for version lookup")
-	updateCmd.Flags().StringVarP(&regCfg.User, "user", "u", "", "Docker registry user")
-	updateCmd.Flags().StringVarP(&regCfg.Password, "password", "p", "", "Docker registry password")
-	updateCmd.Flags().BoolVarP(&silent, "silent", "s", false, "Do not print summary")
+// failureError returns an error if the version lookup of any service failed,
+// so that the command exits with an error after the remaining services have
+// been processed.
+func failureError(f failureReporter) error {
+	if f.HasFailures() {
+		return errors.New("the version lookup failed for some services (see summary)")
+	}
+	return nil
 }
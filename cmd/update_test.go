@@ -0,0 +1,20 @@
+package cmd
+
+import "testing"
+
+type failureReporterMock struct {
+	hasFailures bool
+}
+
+func (f *failureReporterMock) HasFailures() bool {
+	return f.hasFailures
+}
+
+func TestFailureError(t *testing.T) {
+	if err := failureError(&failureReporterMock{}); err != nil {
+		t.Errorf("expected no error, got '%v'", err)
+	}
+	if err := failureError(&failureReporterMock{hasFailures: true}); err == nil {
+		t.Error("expected error")
+	}
+}
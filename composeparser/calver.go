@@ -0,0 +1,51 @@
+package composeparser
+
+import (
+	"regexp"
+	"strconv"
+)
+
+// reCalVerSeparated matches calendar versions like '2023.01.26' or
+// 'RELEASE.2023-01-12T00-00-00Z'. The groups are the prefix, the year, the
+// separator, the month, the day and the suffix.
+var reCalVerSeparated = regexp.MustCompile(`^(\D*?)((?:19|20)\d{2})([.-])(\d{1,2})([.-])(\d{1,2})(\D.*)?$`)
+
+// reCalVerCompact matches calendar versions like '20230126' or
+// 'jammy-20230126'. The groups are the prefix, the year, the month, the day
+// and the suffix.
+var reCalVerCompact = regexp.MustCompile(`^(\D*?)((?:19|20)\d{2})(\d{2})(\d{2})(\D.*)?$`)
+
+// calVer holds the parts of a calendar version that are not a date. The date
+// itself is stored in the version parts of the image (year, month and day as
+// major, minor and patch).
+type calVer struct {
+	prefix string
+	suffix string
+}
+
+// parseCalVer returns the date and the remaining parts of a calendar version
+// or false if the version is not a calendar version.
+func parseCalVer(version string) (int, int, int, *calVer, bool) {
+	var prefix, year, month, day, suffix string
+	if m := reCalVerSeparated.FindStringSubmatch(version); m != nil && m[3] == m[5] {
+		prefix, year, month, day, suffix = m[1], m[2], m[4], m[6], m[7]
+	} else if m := reCalVerCompact.FindStringSubmatch(version); m != nil {
+		prefix, year, month, day, suffix = m[1], m[2], m[3], m[4], m[5]
+	} else {
+		return 0, 0, 0, nil, false
+	}
+	y, _ := strconv.Atoi(year)
+	mo, _ := strconv.Atoi(month)
+	d, _ := strconv.Atoi(day)
+	if mo < 1 || mo > 12 || d < 1 || d > 31 {
+		return 0, 0, 0, nil, false
+	}
+	return y, mo, d, &calVer{prefix: prefix, suffix: suffix}, true
+}
+
+// sameScheme reports whether both calendar versions have the same prefix and
+// a suffix of the same form, e.g. '-build7' and '-build42'.
+func (c *calVer) sameScheme(comp *calVer) bool {
+	return c.prefix == comp.prefix &&
+		reNumber.ReplaceAllString(c.suffix, "0") == reNumber.ReplaceAllString(comp.suffix, "0")
+}
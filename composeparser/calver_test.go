@@ -0,0 +1,105 @@
+package composeparser
+
+import "testing"
+
+func TestParseCalVer(t *testing.T) {
+	tests := []struct {
+		version        string
+		expectedOk     bool
+		expectedYear   int
+		expectedMonth  int
+		expectedDay    int
+		expectedPrefix string
+		expectedSuffix string
+	}{
+		{"2023.01.26", true, 2023, 1, 26, "", ""},
+		{"2023.1.6", true, 2023, 1, 6, "", ""},
+		{"20230126", true, 2023, 1, 26, "", ""},
+		{"jammy-20230126", true, 2023, 1, 26, "jammy-", ""},
+		{"RELEASE.2023-01-12T00-00-00Z", true, 2023, 1, 12, "RELEASE.", "T00-00-00Z"},
+		{"2023.05.01-build42", true, 2023, 5, 1, "", "-build42"},
+		{"2023.01-26", false, 0, 0, 0, "", ""},
+		{"20231326", false, 0, 0, 0, "", ""},
+		{"202301261", false, 0, 0, 0, "", ""},
+		{"1.2.3", false, 0, 0, 0, "", ""},
+		{"22.04", false, 0, 0, 0, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			year, month, day, calVer, ok := parseCalVer(tt.version)
+			if tt.expectedOk != ok {
+				t.Fatalf("expected '%v', got '%v'", tt.expectedOk, ok)
+			}
+			if !ok {
+				return
+			}
+			if tt.expectedYear != year || tt.expectedMonth != month || tt.expectedDay != day {
+				t.Errorf("expected '%v-%v-%v', got '%v-%v-%v'", tt.expectedYear, tt.expectedMonth, tt.expectedDay, year, month, day)
+			}
+			if tt.expectedPrefix != calVer.prefix || tt.expectedSuffix != calVer.suffix {
+				t.Errorf("expected '%v' and '%v', got '%v' and '%v'", tt.expectedPrefix, tt.expectedSuffix, calVer.prefix, calVer.suffix)
+			}
+		})
+	}
+}
+
+func TestGetLatestVersion_calVer(t *testing.T) {
+	tests := []struct {
+		name        string
+		imageStr    string
+		updateMode  updateMode
+		regVersions []string
+		expected    string
+	}{
+		{
+			"compact with prefix",
+			"ubuntu:jammy-20221130",
+			updateMajor,
+			[]string{"jammy-20230126", "focal-20230301", "jammy-20221130", "jammy", "22.04"},
+			"ubuntu:jammy-20230126",
+		},
+		{
+			"chronological order with time",
+			"minio/minio:RELEASE.2022-12-24T10-05-00Z",
+			updateMajor,
+			[]string{"RELEASE.2023-01-12T10-00-00Z", "RELEASE.2023-01-12T09-30-00Z", "RELEASE.2022-12-24T10-05-00Z"},
+			"minio/minio:RELEASE.2023-01-12T10-00-00Z",
+		},
+		{
+			"suffix with build number",
+			"some/app:2023.04.01-build7",
+			updateMajor,
+			[]string{"2023.05.01-build9", "2023.05.01-build42", "2023.06.01-rc1"},
+			"some/app:2023.05.01-build42",
+		},
+		{
+			"minor stays within the same year",
+			"some/app:2022.04.01",
+			updateMinor,
+			[]string{"2022.12.24", "2023.01.02"},
+			"some/app:2022.12.24",
+		},
+		{
+			"patch stays within the same month",
+			"some/app:20220401",
+			updatePatch,
+			[]string{"20220430", "20220501"},
+			"some/app:20220430",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img, err := newImageFromString(tt.imageStr)
+			if err != nil {
+				t.Fatalf("expected no error, got '%v'", err)
+			}
+			reg := &registryMock{
+				getImageVersionsFn: func(imageName string) ([]string, error) {
+					return tt.regVersions, nil
+				},
+			}
+			latestImg, err := img.GetLatestVersion(reg, tt.updateMode)
+			expectVersion(t, latestImg, err, tt.expected)
+		})
+	}
+}
@@ -0,0 +1,237 @@
+package composeparser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// constraint restricts the versions an image can be updated to, e.g.
+// '>=3.16 <3.19 !=3.17.1' or '~14.2'. The comparisons separated by whitespace
+// (or commas) all have to be satisfied, alternatives are separated by '||'.
+type constraint struct {
+	str    string
+	groups [][]comparison
+}
+
+// comparison reports whether a version satisfies a single comparison of a
+// constraint.
+type comparison func(v semver) bool
+
+// semver is a version reduced to the parts that are relevant for the
+// precedence.
+type semver struct {
+	major      int
+	minor      int
+	patch      int
+	prerelease string
+}
+
+// constraintOperators are checked in order, so longer operators have to come
+// before their prefixes.
+var constraintOperators = []string{">=", "<=", "!=", "==", ">", "<", "=", "~", "^"}
+
+// newConstraint parses a constraint. The following operators are supported:
+//
+//	=, !=, >, >=, <, <=  compare the version, '=' may be omitted
+//	~1.2.3               allows patch updates (>=1.2.3 <1.3.0), '~1' allows minor updates
+//	^1.2.3               allows updates that do not change the left-most non-zero part (>=1.2.3 <2.0.0)
+//
+// Versions may be partial or contain wildcards ('x', 'X' or '*'), e.g. '=3.17'
+// matches all 3.17.x versions and '<2' all versions below 2.0.0.
+func newConstraint(str string) (*constraint, error) {
+	c := &constraint{str: str}
+	for _, group := range strings.Split(str, "||") {
+		comparisons, err := parseComparisons(group)
+		if err != nil {
+			return nil, fmt.Errorf("invalid constraint '%v': %v", str, err)
+		}
+		c.groups = append(c.groups, comparisons)
+	}
+	return c, nil
+}
+
+func parseComparisons(group string) ([]comparison, error) {
+	fields := strings.FieldsFunc(group, func(r rune) bool {
+		return r == ' ' || r == '\t' || r == ','
+	})
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("empty constraint")
+	}
+	comparisons := []comparison{}
+	for idx := 0; idx < len(fields); idx++ {
+		field := fields[idx]
+		// the operator may be separated from the version, e.g. '>= 1.4'
+		if isConstraintOperator(field) && idx+1 < len(fields) {
+			idx++
+			field += fields[idx]
+		}
+		cmp, err := parseComparison(field)
+		if err != nil {
+			return nil, err
+		}
+		comparisons = append(comparisons, cmp)
+	}
+	return comparisons, nil
+}
+
+func isConstraintOperator(str string) bool {
+	for _, op := range constraintOperators {
+		if str == op {
+			return true
+		}
+	}
+	return false
+}
+
+func parseComparison(str string) (comparison, error) {
+	op := ""
+	for _, o := range constraintOperators {
+		if strings.HasPrefix(str, o) {
+			op = o
+			break
+		}
+	}
+	v, parts, err := parsePartialVersion(strings.TrimPrefix(str, op))
+	if err != nil {
+		return nil, err
+	}
+	// upper is the (exclusive) upper bound of a partial version, e.g. '2.0.0'
+	// for '1' and '1.3.0' for '1.2'
+	upper := v
+	switch parts {
+	case 1:
+		upper = semver{major: v.major + 1}
+	case 2:
+		upper = semver{major: v.major, minor: v.minor + 1}
+	}
+	inRange := func(v semver, lower semver, upper semver) bool {
+		return compareSemver(v, lower) >= 0 && compareSemver(v, upper) < 0
+	}
+	matches := func(x semver) bool {
+		if parts == 0 {
+			return true
+		}
+		if parts == 3 {
+			return compareSemver(x, v) == 0
+		}
+		return inRange(x, v, upper)
+	}
+
+	switch op {
+	case "", "=", "==":
+		return matches, nil
+	case "!=":
+		return func(x semver) bool { return !matches(x) }, nil
+	case ">":
+		return func(x semver) bool {
+			if parts == 3 {
+				return compareSemver(x, v) > 0
+			}
+			return parts != 0 && compareSemver(x, upper) >= 0
+		}, nil
+	case ">=":
+		return func(x semver) bool { return compareSemver(x, v) >= 0 }, nil
+	case "<":
+		return func(x semver) bool { return parts != 0 && compareSemver(x, v) < 0 }, nil
+	case "<=":
+		return func(x semver) bool {
+			if parts == 3 {
+				return compareSemver(x, v) <= 0
+			}
+			return parts == 0 || compareSemver(x, upper) < 0
+		}, nil
+	case "~":
+		tildeUpper := semver{major: v.major, minor: v.minor + 1}
+		if parts == 1 {
+			tildeUpper = semver{major: v.major + 1}
+		}
+		return func(x semver) bool { return parts == 0 || inRange(x, v, tildeUpper) }, nil
+	case "^":
+		caretUpper := semver{major: v.major + 1}
+		if v.major == 0 && parts > 1 {
+			caretUpper = semver{minor: v.minor + 1}
+			if v.minor == 0 && parts > 2 {
+				caretUpper = semver{patch: v.patch + 1}
+			}
+		}
+		return func(x semver) bool { return parts == 0 || inRange(x, v, caretUpper) }, nil
+	}
+	return nil, fmt.Errorf("unknown operator in '%v'", str)
+}
+
+// parsePartialVersion parses a version that may be partial (e.g. '1.2') or
+// contain wildcards (e.g. '1.x') and returns the number of given parts.
+func parsePartialVersion(str string) (semver, int, error) {
+	v := semver{}
+	version := strings.TrimPrefix(str, "v")
+	version, _, _ = strings.Cut(version, "+")
+	version, v.prerelease, _ = strings.Cut(version, "-")
+	if version == "" {
+		return v, 0, fmt.Errorf("missing version in '%v'", str)
+	}
+	nums := strings.Split(version, ".")
+	if len(nums) > 3 {
+		return v, 0, fmt.Errorf("invalid version '%v'", str)
+	}
+	parts := 0
+	for _, num := range nums {
+		if num == "x" || num == "X" || num == "*" {
+			break
+		}
+		n, err := strconv.Atoi(num)
+		if err != nil || n < 0 {
+			return v, 0, fmt.Errorf("invalid version '%v'", str)
+		}
+		switch parts {
+		case 0:
+			v.major = n
+		case 1:
+			v.minor = n
+		case 2:
+			v.patch = n
+		}
+		parts++
+	}
+	return v, parts, nil
+}
+
+func compareSemver(a semver, b semver) int {
+	for _, diff := range []int{a.major - b.major, a.minor - b.minor, a.patch - b.patch} {
+		if diff < 0 {
+			return -1
+		}
+		if diff > 0 {
+			return 1
+		}
+	}
+	return comparePrerelease(a.prerelease, b.prerelease)
+}
+
+// check reports whether the version of the image satisfies the constraint.
+func (c *constraint) check(img *image) bool {
+	prerelease, _ := splitSuffix(img.Suffix)
+	v := semver{
+		major:      img.Major,
+		minor:      img.Minor,
+		patch:      img.Patch,
+		prerelease: prerelease,
+	}
+	for _, group := range c.groups {
+		satisfied := true
+		for _, cmp := range group {
+			if !cmp(v) {
+				satisfied = false
+				break
+			}
+		}
+		if satisfied {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *constraint) String() string {
+	return c.str
+}
@@ -0,0 +1,85 @@
+package composeparser
+
+import "testing"
+
+func TestConstraintCheck(t *testing.T) {
+	tests := []struct {
+		constraint string
+		version    string
+		expected   bool
+	}{
+		{"1.2.3", "1.2.3", true},
+		{"1.2.3", "1.2.4", false},
+		{"=3.17", "3.17.5", true},
+		{"=3.17", "3.18.0", false},
+		{"!=3.17.1", "3.17.1", false},
+		{"!=3.17.1", "3.17.2", true},
+		{"!=3.17", "3.17.2", false},
+		{">1.2.3", "1.2.4", true},
+		{">1.2.3", "1.2.3", false},
+		{">1.2", "1.2.9", false},
+		{">1.2", "1.3.0", true},
+		{">=1.4", "1.4.0", true},
+		{">=1.4", "1.3.9", false},
+		{"<2", "1.99.99", true},
+		{"<2", "2.0.0", false},
+		{"<=2.1", "2.1.9", true},
+		{"<=2.1", "2.2.0", false},
+		{"<=2.1.0", "2.1.1", false},
+		{"~14.2", "14.2.9", true},
+		{"~14.2", "14.3.0", false},
+		{"~14", "14.9.0", true},
+		{"~14", "15.0.0", false},
+		{"~1.2.3", "1.2.2", false},
+		{"^1.2.3", "1.9.0", true},
+		{"^1.2.3", "2.0.0", false},
+		{"^0.2.3", "0.2.9", true},
+		{"^0.2.3", "0.3.0", false},
+		{"^0.0.3", "0.0.4", false},
+		{"1.x", "1.5.0", true},
+		{"1.x", "2.0.0", false},
+		{"*", "9.9.9", true},
+		{"v1.2.3", "1.2.3", true},
+		{">=3.16 <3.19 !=3.17.1", "3.17.1", false},
+		{">=3.16 <3.19 !=3.17.1", "3.18.2", true},
+		{">=3.16 <3.19 !=3.17.1", "3.19.0", false},
+		{">=3.16, <3.19", "3.16.0", true},
+		{">= 1.4 < 2", "1.5.0", true},
+		{"<1 || >=3", "2.0.0", false},
+		{"<1 || >=3", "3.0.0", true},
+		{"<1.2.3", "1.2.3-rc.1", true},
+		{">=1.2.3-rc.2", "1.2.3-rc.10", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.constraint+"_"+tt.version, func(t *testing.T) {
+			c, err := newConstraint(tt.constraint)
+			if err != nil {
+				t.Fatalf("expected no error, got '%v'", err)
+			}
+			img := &image{}
+			img.setVersionFromStr(tt.version)
+			actual := c.check(img)
+			if tt.expected != actual {
+				t.Errorf("expected '%v', got '%v'", tt.expected, actual)
+			}
+		})
+	}
+}
+
+func TestNewConstraint_invalid(t *testing.T) {
+	tests := []string{
+		"",
+		">=",
+		">=a.b",
+		"1.2.3.4",
+		"<1 ||",
+	}
+	for _, tt := range tests {
+		t.Run(tt, func(t *testing.T) {
+			_, err := newConstraint(tt)
+			if err == nil {
+				t.Error("expected error, got nil")
+			}
+		})
+	}
+}
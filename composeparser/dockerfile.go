@@ -0,0 +1,273 @@
+package composeparser
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// reFrom matches a FROM instruction, the first group is the image and the
+// optional second group the stage name.
+var reFrom = regexp.MustCompile(`(?i)^\s*FROM\s+(?:--\S+\s+)*(\S+)(?:\s+AS\s+(\S+))?\s*$`)
+
+// reArg matches an ARG instruction with a default value, the first group is
+// the name and one of the following groups the (optionally quoted) value.
+var reArg = regexp.MustCompile(`(?i)^\s*ARG\s+([A-Za-z_][A-Za-z0-9_]*)=(?:"([^"]*)"|'([^']*)'|(\S*))\s*$`)
+
+// reArgRef matches a reference to a build argument, e.g. '$VERSION' or
+// '${VERSION}'.
+var reArgRef = regexp.MustCompile(`\$(?:([A-Za-z_][A-Za-z0-9_]*)|\{([A-Za-z_][A-Za-z0-9_]*)\})`)
+
+type dockerfile struct {
+	file   string
+	format fileFormat
+	lines  []string
+	args   map[string]*dockerfileArg
+	froms  []*fromInstruction
+}
+
+// dockerfileArg is a build argument with a default value.
+type dockerfileArg struct {
+	line        int
+	valueStart  int
+	valueEnd    int
+	headComment string
+}
+
+// fromInstruction references the part of a line that holds the image of a
+// FROM instruction. This is either the image in the FROM instruction itself or
+// the default value of the build argument the image (or its tag) is taken from.
+type fromInstruction struct {
+	line    int
+	start   int
+	end     int
+	tagOnly bool
+	service *service
+}
+
+func newDockerfile(file string, namePrefix string) (*dockerfile, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	d := &dockerfile{
+		file: file,
+	}
+	err = d.parse(f, namePrefix)
+	return d, err
+}
+
+// isDockerfileName reports whether the file name is a common name for a
+// Dockerfile, e.g. 'Dockerfile', 'Dockerfile.dev' or 'app.dockerfile'.
+func isDockerfileName(file string) bool {
+	name := strings.ToLower(filepath.Base(file))
+	return name == "dockerfile" ||
+		name == "containerfile" ||
+		strings.HasPrefix(name, "dockerfile.") ||
+		strings.HasSuffix(name, ".dockerfile")
+}
+
+// parse reads the FROM instructions of the Dockerfile. Each FROM instruction
+// that references an image (and not 'scratch' or a previous build stage) is
+// represented by a service named after its build stage.
+func (d *dockerfile) parse(reader io.Reader, namePrefix string) error {
+	b, err := io.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+	// the line endings stay part of the lines, so that files with mixed line
+	// endings are kept as they are
+	b, d.format = splitFormat(b)
+	d.lines = strings.Split(string(b), "\n")
+	d.args = map[string]*dockerfileArg{}
+
+	stages := map[string]bool{}
+	stageIdx := 0
+	for lineIdx, line := range d.lines {
+		// only arguments declared before the first FROM instruction can be
+		// used in FROM instructions
+		if loc := reArg.FindStringSubmatchIndex(line); loc != nil && stageIdx == 0 {
+			d.addArg(lineIdx, loc)
+			continue
+		}
+		loc := reFrom.FindStringSubmatchIndex(line)
+		if loc == nil {
+			continue
+		}
+		imgStr := line[loc[2]:loc[3]]
+		stageName := "stage-" + strconv.Itoa(stageIdx)
+		if loc[4] >= 0 {
+			stageName = line[loc[4]:loc[5]]
+		}
+		stageIdx++
+
+		isStage := stages[strings.ToLower(imgStr)]
+		stages[strings.ToLower(stageName)] = true
+		if isStage || imgStr == "scratch" {
+			continue
+		}
+
+		from := &fromInstruction{
+			line:  lineIdx,
+			start: loc[2],
+			end:   loc[3],
+		}
+		headComment := d.headComment(lineIdx)
+		if strings.Contains(imgStr, "$") {
+			var argComment string
+			var ok bool
+			imgStr, argComment, ok = d.resolveArgs(from, imgStr)
+			if !ok {
+				// the image can not be resolved or updated
+				continue
+			}
+			headComment = argComment + "\n" + headComment
+		}
+
+		from.service = &service{
+			name:    namePrefix + stageName,
+			options: newServiceOptions(headComment, ""),
+		}
+		from.service.setImage(imgStr)
+		d.froms = append(d.froms, from)
+	}
+	return nil
+}
+
+func (d *dockerfile) addArg(lineIdx int, loc []int) {
+	arg := &dockerfileArg{
+		line:        lineIdx,
+		headComment: d.headComment(lineIdx),
+	}
+	for group := 2; group <= 4; group++ {
+		if loc[group*2] >= 0 {
+			arg.valueStart, arg.valueEnd = loc[group*2], loc[group*2+1]
+			break
+		}
+	}
+	name := d.lines[lineIdx][loc[2]:loc[3]]
+	d.args[name] = arg
+}
+
+// resolveArgs replaces the build argument references in the image with their
+// default values and returns the head comment of the argument the image is
+// taken from. The FROM instruction is changed to reference the argument value
+// if either the whole image or exactly its tag is taken from a single
+// argument. If the image can not be resolved or updated, false is returned.
+func (d *dockerfile) resolveArgs(from *fromInstruction, imgStr string) (string, string, bool) {
+	var unresolved bool
+	resolved := reArgRef.ReplaceAllStringFunc(imgStr, func(ref string) string {
+		arg, ok := d.args[argRefName(ref)]
+		if !ok {
+			unresolved = true
+			return ref
+		}
+		return d.argValue(arg)
+	})
+	if unresolved {
+		return "", "", false
+	}
+
+	if isSingleArgRef(imgStr) {
+		arg := d.args[argRefName(imgStr)]
+		from.line, from.start, from.end = arg.line, arg.valueStart, arg.valueEnd
+		return resolved, arg.headComment, true
+	}
+
+	// the digest is replaced together with the tag
+	name, _, _ := strings.Cut(imgStr, "@")
+	tag := ""
+	if idx := strings.LastIndex(name, ":"); idx > strings.LastIndex(name, "/") {
+		name, tag = name[:idx], name[idx+1:]
+	}
+	if isSingleArgRef(tag) && !strings.Contains(imgStr, "@") {
+		arg := d.args[argRefName(tag)]
+		from.line, from.start, from.end = arg.line, arg.valueStart, arg.valueEnd
+		from.tagOnly = true
+		return resolved, arg.headComment, true
+	}
+	if tag != "" && !strings.Contains(tag, "$") {
+		// only the image name contains arguments, the tag can be updated in place
+		from.start += len(name) + 1
+		from.tagOnly = true
+		return resolved, "", true
+	}
+	return "", "", false
+}
+
+func isSingleArgRef(str string) bool {
+	loc := reArgRef.FindStringIndex(str)
+	return loc != nil && loc[0] == 0 && loc[1] == len(str)
+}
+
+func (d *dockerfile) argValue(arg *dockerfileArg) string {
+	return d.lines[arg.line][arg.valueStart:arg.valueEnd]
+}
+
+func argRefName(ref string) string {
+	return strings.Trim(ref, "${}")
+}
+
+// headComment returns the comment lines directly above the given line.
+func (d *dockerfile) headComment(lineIdx int) string {
+	start := lineIdx
+	for start > 0 && strings.HasPrefix(strings.TrimSpace(d.lines[start-1]), "#") {
+		start--
+	}
+	return strings.Join(d.lines[start:lineIdx], "\n")
+}
+
+func (d *dockerfile) services() []*service {
+	services := []*service{}
+	for _, from := range d.froms {
+		services = append(services, from.service)
+	}
+	return services
+}
+
+func (d *dockerfile) hasChanges() bool {
+	for _, from := range d.froms {
+		if from.service.hasChanged() {
+			return true
+		}
+	}
+	return false
+}
+
+// marshal returns the content of the Dockerfile with the image of every FROM
+// instruction replaced by its latest version. All other content is kept as is,
+// the line endings can be overridden (see Options.LineEndings).
+func (d *dockerfile) marshal(lineEndings string) []byte {
+	lines := make([]string, len(d.lines))
+	copy(lines, d.lines)
+	// several FROM instructions may take their image from the same argument
+	replaced := map[int]bool{}
+	for _, from := range d.froms {
+		s := from.service
+		if !s.hasChanged() || replaced[from.line] {
+			continue
+		}
+		replaced[from.line] = true
+		value := s.latestImage.String()
+		if from.tagOnly {
+			value = s.latestImage.taggedDigest()
+		}
+		line := lines[from.line]
+		lines[from.line] = line[:from.start] + value + line[from.end:]
+	}
+	return d.format.restore([]byte(strings.Join(lines, "\n")), lineEndings)
+}
+
+// writeIfChanged writes the Dockerfile back to its original file if the image
+// of any FROM instruction has changed.
+func (d *dockerfile) writeIfChanged(lineEndings string) error {
+	if !d.hasChanges() {
+		return nil
+	}
+	return os.WriteFile(d.file, d.marshal(lineEndings), 0644)
+}
@@ -0,0 +1,376 @@
+package composeparser
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func dockerfileFromStr(s string) (d *dockerfile, err error) {
+	d = &dockerfile{}
+	err = d.parse(strings.NewReader(s), "")
+	return
+}
+
+func TestDockerfile_parse(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected map[string]string
+	}{
+		{
+			"single stage",
+			"FROM alpine:3.15.5\nRUN echo test\n",
+			map[string]string{
+				"stage-0": "alpine:3.15.5",
+			},
+		},
+		{
+			"named stages",
+			"FROM golang:1.19.1 AS builder\nRUN go build\n\nfrom alpine:3.15.5 as final\nCOPY --from=builder /app /app\n",
+			map[string]string{
+				"builder": "golang:1.19.1",
+				"final":   "alpine:3.15.5",
+			},
+		},
+		{
+			"reference to previous stage",
+			"FROM golang:1.19.1 AS builder\nFROM builder AS test\nFROM alpine:3.15.5\n",
+			map[string]string{
+				"builder": "golang:1.19.1",
+				"stage-2": "alpine:3.15.5",
+			},
+		},
+		{
+			"scratch",
+			"FROM golang:1.19.1 AS builder\nFROM scratch\n",
+			map[string]string{
+				"builder": "golang:1.19.1",
+			},
+		},
+		{
+			"flags",
+			"FROM --platform=linux/amd64 golang:1.19.1 AS builder\n",
+			map[string]string{
+				"builder": "golang:1.19.1",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := dockerfileFromStr(tt.content)
+			if err != nil {
+				t.Fatalf("expected no error, got '%v'", err)
+			}
+			actual := map[string]string{}
+			for _, s := range d.services() {
+				actual[s.name] = s.currentImage.String()
+			}
+			if !reflect.DeepEqual(tt.expected, actual) {
+				t.Errorf("expected %v, got %v", tt.expected, actual)
+			}
+		})
+	}
+}
+
+func TestDockerfile_marshal(t *testing.T) {
+	d, err := dockerfileFromStr("# comment\r\n" +
+		"FROM --platform=$BUILDPLATFORM golang:0.1.0 AS builder\r\n" +
+		"RUN go build\r\n" +
+		"FROM alpine:0.1.0\r\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &parser{dockerfiles: []*dockerfile{d}}
+	err = p.UpdateVersions(&registryMock{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !d.hasChanges() {
+		t.Error("expected changes")
+	}
+	actual := string(d.marshal(""))
+	const expected = "# comment\r\n" +
+		"FROM --platform=$BUILDPLATFORM golang:1.0.0 AS builder\r\n" +
+		"RUN go build\r\n" +
+		"FROM alpine:1.0.0\r\n"
+	if actual != expected {
+		t.Errorf("expected '%q', got '%q'", expected, actual)
+	}
+}
+
+func TestDockerfile_marshalLineEndings(t *testing.T) {
+	d, err := dockerfileFromStr(utf8BOM + "FROM alpine:0.1.0\r\n" +
+		"RUN apk add curl\r\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &parser{dockerfiles: []*dockerfile{d}}
+	err = p.UpdateVersions(&registryMock{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		lineEndings string
+		expected    string
+	}{
+		{LineEndingsAuto, utf8BOM + "FROM alpine:1.0.0\r\nRUN apk add curl\r\n"},
+		{LineEndingsLF, utf8BOM + "FROM alpine:1.0.0\nRUN apk add curl\n"},
+	}
+	for _, tt := range tests {
+		actual := string(d.marshal(tt.lineEndings))
+		if actual != tt.expected {
+			t.Errorf("expected '%q', got '%q'", tt.expected, actual)
+		}
+	}
+}
+
+func TestDockerfile_marshalDigest(t *testing.T) {
+	d, err := dockerfileFromStr("ARG VERSION=0.1.0\n" +
+		"ARG REGISTRY=docker.io\n" +
+		"FROM golang:0.1.0@sha256:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa AS builder\n" +
+		"FROM $REGISTRY/alpine:${VERSION}\n" +
+		"FROM $REGISTRY/nginx:0.1.0@sha256:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &parser{opts: Options{Digest: true}, dockerfiles: []*dockerfile{d}}
+	err = p.UpdateVersions(&registryMock{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	actual := string(d.marshal(""))
+	const expected = "ARG VERSION=1.0.0@sha256:1.0.0\n" +
+		"ARG REGISTRY=docker.io\n" +
+		"FROM golang:1.0.0@sha256:1.0.0 AS builder\n" +
+		"FROM $REGISTRY/alpine:${VERSION}\n" +
+		"FROM $REGISTRY/nginx:1.0.0@sha256:1.0.0\n"
+	if actual != expected {
+		t.Errorf("expected '%q', got '%q'", expected, actual)
+	}
+}
+
+func TestDockerfile_writeIfChanged(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "Dockerfile")
+	const content = "FROM alpine:1.0.0\n"
+	err := os.WriteFile(file, []byte(content), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := newDockerfile(file, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &parser{dockerfiles: []*dockerfile{d}}
+	err = p.UpdateVersions(&registryMock{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.hasChanges() {
+		t.Error("expected no changes")
+	}
+	err = os.Remove(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = d.writeIfChanged("")
+	if err != nil {
+		t.Fatalf("expected no error, got '%v'", err)
+	}
+	if _, err := os.Stat(file); !os.IsNotExist(err) {
+		t.Error("expected unchanged Dockerfile not to be written")
+	}
+}
+
+func TestDockerfile_args(t *testing.T) {
+	tests := []struct {
+		name             string
+		content          string
+		expectedImages   map[string]string
+		expectedMarshal  string
+		expectedServices int
+	}{
+		{
+			"argument for tag",
+			"ARG GO_VERSION=0.1.0\nFROM golang:${GO_VERSION} AS builder\n",
+			map[string]string{"builder": "golang:0.1.0"},
+			"ARG GO_VERSION=1.0.0\nFROM golang:${GO_VERSION} AS builder\n",
+			1,
+		},
+		{
+			"argument for image",
+			"ARG BASE=\"alpine:0.1.0\"\nFROM $BASE\n",
+			map[string]string{"stage-0": "alpine:0.1.0"},
+			"ARG BASE=\"alpine:1.0.0\"\nFROM $BASE\n",
+			1,
+		},
+		{
+			"argument for image name",
+			"ARG REGISTRY=registry.example.com\nFROM ${REGISTRY}/app:0.1.0\n",
+			map[string]string{"stage-0": "registry.example.com/app:0.1.0"},
+			"ARG REGISTRY=registry.example.com\nFROM ${REGISTRY}/app:1.0.0\n",
+			1,
+		},
+		{
+			"argument shared by several stages",
+			"ARG VERSION=0.1.0\nFROM alpine:${VERSION} AS a\nFROM alpine:${VERSION} AS b\n",
+			map[string]string{"a": "alpine:0.1.0", "b": "alpine:0.1.0"},
+			"ARG VERSION=1.0.0\nFROM alpine:${VERSION} AS a\nFROM alpine:${VERSION} AS b\n",
+			2,
+		},
+		{
+			"argument without default",
+			"ARG VERSION\nFROM alpine:${VERSION}\n",
+			map[string]string{},
+			"ARG VERSION\nFROM alpine:${VERSION}\n",
+			0,
+		},
+		{
+			"argument declared in stage",
+			"FROM alpine:0.1.0\nARG VERSION=0.1.0\nFROM alpine:${VERSION}\n",
+			map[string]string{"stage-0": "alpine:0.1.0"},
+			"FROM alpine:1.0.0\nARG VERSION=0.1.0\nFROM alpine:${VERSION}\n",
+			1,
+		},
+		{
+			"tag composed of several arguments",
+			"ARG MAJOR=0\nARG MINOR=1\nFROM alpine:${MAJOR}.${MINOR}\n",
+			map[string]string{},
+			"ARG MAJOR=0\nARG MINOR=1\nFROM alpine:${MAJOR}.${MINOR}\n",
+			0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := dockerfileFromStr(tt.content)
+			if err != nil {
+				t.Fatalf("expected no error, got '%v'", err)
+			}
+			actual := map[string]string{}
+			for _, s := range d.services() {
+				actual[s.name] = s.currentImage.String()
+			}
+			if !reflect.DeepEqual(tt.expectedImages, actual) {
+				t.Errorf("expected %v, got %v", tt.expectedImages, actual)
+			}
+			p := &parser{dockerfiles: []*dockerfile{d}}
+			err = p.UpdateVersions(&registryMock{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			actualMarshal := string(d.marshal(""))
+			if tt.expectedMarshal != actualMarshal {
+				t.Errorf("expected '%q', got '%q'", tt.expectedMarshal, actualMarshal)
+			}
+		})
+	}
+}
+
+func TestDockerfile_annotations(t *testing.T) {
+	d, err := dockerfileFromStr(`# syntax=docker/dockerfile:1
+# impose:minor
+ARG NODE_VERSION=18.1.0
+
+# impose:warnMajor
+FROM node:${NODE_VERSION} AS builder
+
+# impose:ignore
+FROM alpine:3.15.5
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	actual := map[string]serviceOptions{}
+	for _, s := range d.services() {
+		actual[s.name] = *s.options
+	}
+	expected := map[string]serviceOptions{
+		"builder": {
+			onlyMinor: true,
+			warnMajor: true,
+		},
+		"stage-1": {
+			ignore: true,
+		},
+	}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected %+v, got %+v", expected, actual)
+	}
+}
+
+func TestIsDockerfileName(t *testing.T) {
+	tests := []struct {
+		file     string
+		expected bool
+	}{
+		{"Dockerfile", true},
+		{"some/dir/Dockerfile", true},
+		{"dockerfile", true},
+		{"Dockerfile.dev", true},
+		{"app.Dockerfile", true},
+		{"Containerfile", true},
+		{"docker-compose.yml", false},
+		{"Dockerfile-compose.yml", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			actual := isDockerfileName(tt.file)
+			if tt.expected != actual {
+				t.Errorf("expected '%v', got '%v'", tt.expected, actual)
+			}
+		})
+	}
+}
+
+func TestNewParser_dockerfile(t *testing.T) {
+	tmpDir := t.TempDir()
+	tests := []struct {
+		name string
+		file string
+		opts *Options
+	}{
+		{
+			"detected by file name",
+			filepath.Join(tmpDir, "Dockerfile"),
+			nil,
+		},
+		{
+			"forced by option",
+			filepath.Join(tmpDir, "build.txt"),
+			&Options{Dockerfile: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := os.WriteFile(tt.file, []byte("FROM alpine:0.1.0\n"), 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+			p, err := NewParser(tt.file, tt.opts)
+			if err != nil {
+				t.Fatalf("expected no error, got '%v'", err)
+			}
+			err = p.UpdateVersions(&registryMock{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !p.HasUpdates() {
+				t.Error("expected updates")
+			}
+			err = p.WriteToOriginalFile()
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := os.ReadFile(tt.file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			const expected = "FROM alpine:1.0.0\n"
+			if string(b) != expected {
+				t.Errorf("expected '%q', got '%q'", expected, string(b))
+			}
+		})
+	}
+}
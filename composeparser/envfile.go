@@ -0,0 +1,206 @@
+package composeparser
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// reEnvLine matches a variable definition in an env file, e.g.
+// 'PG_VERSION=14.5' or 'export TAG="1.0"'. The groups are the name and the
+// raw value.
+var reEnvLine = regexp.MustCompile(`^\s*(?:export\s+)?([A-Za-z_][A-Za-z0-9_.-]*)\s*=\s*(.*)$`)
+
+// envFile is a file with variable definitions like the '.env' file of a
+// Docker Compose project. Variables are updated in place, so that the order,
+// the comments and the quoting of the file are kept.
+type envFile struct {
+	file    string
+	format  fileFormat
+	lines   []string
+	vars    map[string]*envVar
+	mu      sync.Mutex
+	changed bool
+}
+
+// envVar references the value of a variable in the lines of an env file.
+type envVar struct {
+	line       int
+	valueStart int
+	valueEnd   int
+	value      string
+	quote      string
+	// headComment holds the comment lines directly above the variable and
+	// lineComment the comment behind its value, e.g. '# impose:minor'
+	headComment string
+	lineComment string
+	// updated is the value the variable has been updated to
+	updated *string
+}
+
+// loadEnvFile reads the env file. A missing file is no error, nil is returned
+// instead.
+func loadEnvFile(file string) (*envFile, error) {
+	b, err := os.ReadFile(file)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	e := &envFile{file: file}
+	e.parse(b)
+	return e, nil
+}
+
+func (e *envFile) parse(b []byte) {
+	b, e.format = splitFormat(b)
+	e.lines = strings.Split(string(b), "\n")
+	e.vars = map[string]*envVar{}
+	comments := []string{}
+	for idx, line := range e.lines {
+		line = strings.TrimSuffix(line, "\r")
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			comments = append(comments, line)
+			continue
+		}
+		headComment := strings.Join(comments, "\n")
+		comments = []string{}
+		m := reEnvLine.FindStringSubmatchIndex(line)
+		if m == nil {
+			continue
+		}
+		v := &envVar{line: idx, valueStart: m[4], headComment: headComment}
+		raw := line[m[4]:m[5]]
+		rest := ""
+		switch {
+		case len(raw) > 0 && (raw[0] == '"' || raw[0] == '\''):
+			v.quote = raw[:1]
+			end := strings.Index(raw[1:], v.quote)
+			if end < 0 {
+				// an unterminated quote is taken literally
+				v.quote = ""
+				v.valueEnd = m[5]
+				v.value = strings.TrimSpace(raw)
+				break
+			}
+			v.valueStart++
+			v.valueEnd = v.valueStart + end
+			v.value = raw[1 : end+1]
+			rest = raw[end+2:]
+		default:
+			// an unquoted value ends at an inline comment
+			if idx := strings.Index(raw, " #"); idx >= 0 {
+				raw, rest = raw[:idx], raw[idx:]
+			}
+			raw = strings.TrimRight(raw, " \t")
+			v.valueEnd = v.valueStart + len(raw)
+			v.value = raw
+		}
+		if rest = strings.TrimSpace(rest); strings.HasPrefix(rest, "#") {
+			v.lineComment = rest
+		}
+		// later definitions override earlier ones
+		e.vars[line[m[2]:m[3]]] = v
+	}
+}
+
+func (e *envFile) lookup(name string) (string, bool) {
+	v, ok := e.vars[name]
+	if !ok {
+		return "", false
+	}
+	return v.value, true
+}
+
+// options returns the options given by the annotations of the variable.
+func (e *envFile) options(name string) *serviceOptions {
+	v, ok := e.vars[name]
+	if !ok {
+		return &serviceOptions{}
+	}
+	return newServiceOptions(v.headComment, v.lineComment)
+}
+
+// set updates the value of the variable. Updating a variable to different
+// values (e.g. by services with different update modes) is an error.
+func (e *envFile) set(name string, value string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	v, ok := e.vars[name]
+	if !ok {
+		return fmt.Errorf("no variable '%v' in '%v'", name, e.file)
+	}
+	if v.updated != nil && *v.updated != value {
+		return fmt.Errorf("conflicting updates of variable '%v' in '%v': '%v' and '%v'", name, e.file, *v.updated, value)
+	}
+	v.updated = &value
+	e.changed = e.changed || value != v.value
+	return nil
+}
+
+// hasChanges reports whether any variable has changed.
+func (e *envFile) hasChanges() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.changed
+}
+
+// marshal returns the content of the env file with the updated values.
+func (e *envFile) marshal(lineEndings string) []byte {
+	lines := make([]string, len(e.lines))
+	copy(lines, e.lines)
+	for _, v := range e.vars {
+		if v.updated == nil {
+			continue
+		}
+		line := lines[v.line]
+		lines[v.line] = line[:v.valueStart] + *v.updated + line[v.valueEnd:]
+	}
+	return e.format.restore([]byte(strings.Join(lines, "\n")), lineEndings)
+}
+
+// writeIfChanged writes the env file back to its original file if any
+// variable has changed. The file is written only once, even if it is shared
+// by several Docker Compose files.
+func (e *envFile) writeIfChanged(lineEndings string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if !e.changed {
+		return nil
+	}
+	err := os.WriteFile(e.file, e.marshal(lineEndings), 0644)
+	if err != nil {
+		return err
+	}
+	e.changed = false
+	return nil
+}
+
+// envFileCache caches the env files by their path, so that Docker Compose
+// files in the same directory (e.g. override files) share their env file.
+type envFileCache struct {
+	mu    sync.Mutex
+	files map[string]*envFile
+}
+
+func (c *envFileCache) load(file string) (*envFile, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.files[file]; ok {
+		return e, nil
+	}
+	e, err := loadEnvFile(file)
+	if err != nil {
+		return nil, err
+	}
+	if c.files == nil {
+		c.files = map[string]*envFile{}
+	}
+	c.files[file] = e
+	return e, nil
+}
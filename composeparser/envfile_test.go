@@ -0,0 +1,138 @@
+package composeparser
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEnvFile_parse(t *testing.T) {
+	e := &envFile{}
+	e.parse([]byte("# tags\r\n" +
+		"REDIS_TAG=7.0.5\r\n" +
+		"export PG_TAG = \"14.5\"\r\n" +
+		"NGINX_TAG='1.23.2' # stable\r\n" +
+		"API_TAG=1.2.3   # api\r\n" +
+		"EMPTY=\r\n" +
+		"# OLD_TAG=1.0\r\n" +
+		"REDIS_TAG=7.0.6\r\n"))
+	expected := map[string]string{
+		"REDIS_TAG": "7.0.6",
+		"PG_TAG":    "14.5",
+		"NGINX_TAG": "1.23.2",
+		"API_TAG":   "1.2.3",
+		"EMPTY":     "",
+	}
+	if len(e.vars) != len(expected) {
+		t.Errorf("expected %v variables, got %v", len(expected), len(e.vars))
+	}
+	for name, value := range expected {
+		actual, ok := e.lookup(name)
+		if !ok || actual != value {
+			t.Errorf("expected '%v' for '%v', got '%v'", value, name, actual)
+		}
+	}
+	if _, ok := e.lookup("OLD_TAG"); ok {
+		t.Error("expected commented variable to be ignored")
+	}
+}
+
+func TestEnvFile_options(t *testing.T) {
+	e := &envFile{}
+	e.parse([]byte("# tags\n" +
+		"# impose:minor impose:constraint=~7.0\n" +
+		"REDIS_TAG=7.0.5 # impose:warnMinor\n" +
+		"\n" +
+		"PG_TAG=\"14.5\" # impose:patch\n" +
+		"# impose:ignore\n" +
+		"\n" +
+		"API_TAG=1.2.3\n"))
+	tests := []struct {
+		name     string
+		expected *serviceOptions
+	}{
+		{"REDIS_TAG", &serviceOptions{onlyMinor: true, warnMinor: true, constraint: "~7.0"}},
+		{"PG_TAG", &serviceOptions{onlyPatch: true}},
+		{"API_TAG", &serviceOptions{}},
+		{"UNKNOWN", &serviceOptions{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual := e.options(tt.name)
+			if *actual != *tt.expected {
+				t.Errorf("expected '%+v', got '%+v'", *tt.expected, *actual)
+			}
+		})
+	}
+}
+
+func TestEnvFile_set(t *testing.T) {
+	e := &envFile{}
+	e.parse([]byte("# tags\r\n" +
+		"export PG_TAG=\"14.5\"\r\n" +
+		"NGINX_TAG='1.23.2' # stable\r\n" +
+		"API_TAG=1.2.3   # api\r\n"))
+	for name, value := range map[string]string{"PG_TAG": "14.6", "NGINX_TAG": "1.23.3", "API_TAG": "1.3.0"} {
+		err := e.set(name, value)
+		if err != nil {
+			t.Fatalf("expected no error, got '%v'", err)
+		}
+	}
+	const expected = "# tags\r\n" +
+		"export PG_TAG=\"14.6\"\r\n" +
+		"NGINX_TAG='1.23.3' # stable\r\n" +
+		"API_TAG=1.3.0   # api\r\n"
+	actual := string(e.marshal(""))
+	if actual != expected {
+		t.Errorf("expected '%q', got '%q'", expected, actual)
+	}
+
+	if err := e.set("API_TAG", "1.3.0"); err != nil {
+		t.Errorf("expected no error for the same value, got '%v'", err)
+	}
+	if err := e.set("API_TAG", "1.2.4"); err == nil {
+		t.Error("expected error for conflicting values, got nil")
+	}
+	if err := e.set("UNKNOWN", "1.0"); err == nil {
+		t.Error("expected error for unknown variable, got nil")
+	}
+}
+
+func TestEnvFile_writeIfChanged(t *testing.T) {
+	file := filepath.Join(t.TempDir(), ".env")
+	err := os.WriteFile(file, []byte("TAG=1.0.0\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache := &envFileCache{}
+	e, err := cache.load(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := cache.load(file); again != e {
+		t.Error("expected the env file to be loaded only once")
+	}
+
+	err = e.set("TAG", "1.1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = e.writeIfChanged("")
+	if err != nil {
+		t.Fatalf("expected no error, got '%v'", err)
+	}
+	b, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "TAG=1.1.0\n" {
+		t.Errorf("expected 'TAG=1.1.0\\n', got '%q'", string(b))
+	}
+}
+
+func TestLoadEnvFile_missing(t *testing.T) {
+	e, err := loadEnvFile(filepath.Join(t.TempDir(), ".env"))
+	if err != nil || e != nil {
+		t.Errorf("expected no env file and no error, got '%v' and '%v'", e, err)
+	}
+}
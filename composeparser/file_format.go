@@ -0,0 +1,71 @@
+package composeparser
+
+import (
+	"bytes"
+	"fmt"
+)
+
+const utf8BOM = "\xef\xbb\xbf"
+
+const (
+	LineEndingsAuto = "auto"
+	LineEndingsLF   = "lf"
+	LineEndingsCRLF = "crlf"
+)
+
+// fileFormat holds the properties of the original file that are restored
+// when the file is written.
+type fileFormat struct {
+	bom  bool
+	crlf bool
+}
+
+// splitFormat returns the content without a UTF-8 byte order mark together
+// with the format of the file. The line endings are only considered CRLF if
+// all lines end with CRLF, files with mixed line endings are kept as they are.
+func splitFormat(b []byte) ([]byte, fileFormat) {
+	f := fileFormat{}
+	if bytes.HasPrefix(b, []byte(utf8BOM)) {
+		f.bom = true
+		b = b[len(utf8BOM):]
+	}
+	lineBreaks := bytes.Count(b, []byte("\n"))
+	f.crlf = lineBreaks > 0 && bytes.Count(b, []byte("\r\n")) == lineBreaks
+	return b, f
+}
+
+// restore returns the content with the line endings and the byte order mark
+// of the original file. The line endings can be overridden with 'lf' or
+// 'crlf'.
+func (f fileFormat) restore(b []byte, lineEndings string) []byte {
+	switch {
+	case lineEndings == LineEndingsLF:
+		b = toLF(b)
+	case lineEndings == LineEndingsCRLF || f.crlf:
+		b = bytes.ReplaceAll(toLF(b), []byte("\n"), []byte("\r\n"))
+	}
+	if f.bom {
+		b = append([]byte(utf8BOM), b...)
+	}
+	return b
+}
+
+// lineBreak returns the line break of the written file.
+func (f fileFormat) lineBreak(lineEndings string) string {
+	if lineEndings == LineEndingsCRLF || lineEndings != LineEndingsLF && f.crlf {
+		return "\r\n"
+	}
+	return "\n"
+}
+
+func toLF(b []byte) []byte {
+	return bytes.ReplaceAll(b, []byte("\r\n"), []byte("\n"))
+}
+
+func validateLineEndings(lineEndings string) error {
+	switch lineEndings {
+	case "", LineEndingsAuto, LineEndingsLF, LineEndingsCRLF:
+		return nil
+	}
+	return fmt.Errorf("invalid line endings '%v', must be 'auto', 'lf' or 'crlf'", lineEndings)
+}
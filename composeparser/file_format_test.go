@@ -0,0 +1,90 @@
+package composeparser
+
+import (
+	"testing"
+)
+
+func TestSplitFormat(t *testing.T) {
+	tests := []struct {
+		name            string
+		content         string
+		expectedContent string
+		expected        fileFormat
+	}{
+		{"LF", "a: 1\nb: 2\n", "a: 1\nb: 2\n", fileFormat{}},
+		{"CRLF", "a: 1\r\nb: 2\r\n", "a: 1\r\nb: 2\r\n", fileFormat{crlf: true}},
+		{"mixed", "a: 1\r\nb: 2\n", "a: 1\r\nb: 2\n", fileFormat{}},
+		{"no line break", "a: 1", "a: 1", fileFormat{}},
+		{"BOM", utf8BOM + "a: 1\r\n", "a: 1\r\n", fileFormat{bom: true, crlf: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content, f := splitFormat([]byte(tt.content))
+			if string(content) != tt.expectedContent {
+				t.Errorf("expected '%q', got '%q'", tt.expectedContent, string(content))
+			}
+			if f != tt.expected {
+				t.Errorf("expected '%+v', got '%+v'", tt.expected, f)
+			}
+		})
+	}
+}
+
+func TestFileFormat_restore(t *testing.T) {
+	tests := []struct {
+		name        string
+		format      fileFormat
+		lineEndings string
+		content     string
+		expected    string
+	}{
+		{"LF", fileFormat{}, LineEndingsAuto, "a: 1\nb: 2\n", "a: 1\nb: 2\n"},
+		{"CRLF", fileFormat{crlf: true}, LineEndingsAuto, "a: 1\nb: 2\n", "a: 1\r\nb: 2\r\n"},
+		{"CRLF kept", fileFormat{crlf: true}, "", "a: 1\r\nb: 2\r\n", "a: 1\r\nb: 2\r\n"},
+		{"mixed kept", fileFormat{}, LineEndingsAuto, "a: 1\r\nb: 2\n", "a: 1\r\nb: 2\n"},
+		{"BOM", fileFormat{bom: true}, LineEndingsAuto, "a: 1\n", utf8BOM + "a: 1\n"},
+		{"override LF", fileFormat{crlf: true}, LineEndingsLF, "a: 1\r\nb: 2\r\n", "a: 1\nb: 2\n"},
+		{"override CRLF", fileFormat{}, LineEndingsCRLF, "a: 1\r\nb: 2\n", "a: 1\r\nb: 2\r\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual := string(tt.format.restore([]byte(tt.content), tt.lineEndings))
+			if actual != tt.expected {
+				t.Errorf("expected '%q', got '%q'", tt.expected, actual)
+			}
+		})
+	}
+}
+
+func TestFileFormat_lineBreak(t *testing.T) {
+	tests := []struct {
+		name        string
+		format      fileFormat
+		lineEndings string
+		expected    string
+	}{
+		{"LF", fileFormat{}, LineEndingsAuto, "\n"},
+		{"CRLF", fileFormat{crlf: true}, "", "\r\n"},
+		{"override LF", fileFormat{crlf: true}, LineEndingsLF, "\n"},
+		{"override CRLF", fileFormat{}, LineEndingsCRLF, "\r\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual := tt.format.lineBreak(tt.lineEndings)
+			if actual != tt.expected {
+				t.Errorf("expected '%q', got '%q'", tt.expected, actual)
+			}
+		})
+	}
+}
+
+func TestValidateLineEndings(t *testing.T) {
+	for _, lineEndings := range []string{"", LineEndingsAuto, LineEndingsLF, LineEndingsCRLF} {
+		if err := validateLineEndings(lineEndings); err != nil {
+			t.Errorf("expected no error for '%v', got '%v'", lineEndings, err)
+		}
+	}
+	if err := validateLineEndings("cr"); err == nil {
+		t.Error("expected error, got nil")
+	}
+}
@@ -0,0 +1,163 @@
+package composeparser
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// group processes several files (e.g. a Docker Compose file and its override
+// files) in one run. The versions of all files are looked up with the same
+// registry and the summary is grouped by file.
+type group struct {
+	parsers  []*parser
+	envFiles *envFileCache
+}
+
+func NewGroup() *group {
+	return &group{
+		envFiles: &envFileCache{},
+	}
+}
+
+// Add parses the file and adds it to the group.
+func (g *group) Add(file string, opts *Options) error {
+	o := Options{}
+	if opts != nil {
+		o = *opts
+	}
+	// the files of a project share their '.env' file
+	o.envFiles = g.envFiles
+	p, err := NewParser(file, &o)
+	if err != nil {
+		// errors of the file system already contain the file name
+		var pathErr *fs.PathError
+		if errors.As(err, &pathErr) {
+			return err
+		}
+		return fmt.Errorf("%v: %w", file, err)
+	}
+	g.parsers = append(g.parsers, p)
+	return nil
+}
+
+// UpdateVersions looks up the latest versions of the services of all files.
+// The files are processed concurrently. In fail fast mode the first error of
+// any file is returned.
+func (g *group) UpdateVersions(reg registry) error {
+	eg := &errgroup.Group{}
+	for i := range g.parsers {
+		p := g.parsers[i]
+		eg.Go(func() error {
+			return p.UpdateVersions(reg)
+		})
+	}
+	return eg.Wait()
+}
+
+func (g *group) WriteToOriginalFile() error {
+	for _, p := range g.parsers {
+		err := p.WriteToOriginalFile()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteToStdout writes all files to std out, separated by YAML document
+// separators.
+func (g *group) WriteToStdout() error {
+	for idx, p := range g.parsers {
+		if idx > 0 {
+			fmt.Print("---" + p.lineBreak())
+		}
+		err := p.WriteToStdout()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (g *group) WriteToFile(file string) error {
+	if len(g.parsers) != 1 {
+		return errors.New("an output file can only be written for a single input file")
+	}
+	return g.parsers[0].WriteToFile(file)
+}
+
+// WriteSummary writes the summaries of all files. If the group holds more
+// than one file, the summaries are headed by the file name and indented.
+func (g *group) WriteSummary(w io.Writer) error {
+	if len(g.parsers) == 1 {
+		return g.parsers[0].WriteSummary(w)
+	}
+	b := &strings.Builder{}
+	for idx, p := range g.parsers {
+		if idx > 0 {
+			fmt.Fprintln(b)
+		}
+		fmt.Fprintf(b, "%s:\n", p.file)
+		summary := &strings.Builder{}
+		err := p.WriteSummary(summary)
+		if err != nil {
+			return err
+		}
+		for _, line := range strings.SplitAfter(summary.String(), "\n") {
+			if strings.TrimSpace(line) != "" {
+				b.WriteString("  ")
+			}
+			b.WriteString(line)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteJSONReport writes an array with the reports of all files, even if the
+// group holds a single file.
+func (g *group) WriteJSONReport(w io.Writer) error {
+	reports := []*report{}
+	for _, p := range g.parsers {
+		reports = append(reports, p.report())
+	}
+	return writeJSONReports(w, reports)
+}
+
+// HasUpdates reports whether the version of any service of any file has
+// changed.
+func (g *group) HasUpdates() bool {
+	for _, p := range g.parsers {
+		if p.HasUpdates() {
+			return true
+		}
+	}
+	return false
+}
+
+// HasWarnings reports whether the version change of any service of any file
+// triggers one of its warning annotations.
+func (g *group) HasWarnings() bool {
+	for _, p := range g.parsers {
+		if p.HasWarnings() {
+			return true
+		}
+	}
+	return false
+}
+
+// HasFailures reports whether the version lookup of any service of any file
+// failed.
+func (g *group) HasFailures() bool {
+	for _, p := range g.parsers {
+		if p.HasFailures() {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,247 @@
+package composeparser
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func groupFromStrs(t *testing.T, files map[string]string, names ...string) *group {
+	t.Helper()
+	dir := t.TempDir()
+	g := NewGroup()
+	for _, name := range names {
+		file := filepath.Join(dir, name)
+		err := os.WriteFile(file, []byte(files[name]), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = g.Add(file, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return g
+}
+
+func TestGroup_updateVersions(t *testing.T) {
+	files := map[string]string{
+		"docker-compose.yml": `services:
+    web:
+        image: alpine:0.1.0
+`,
+		"docker-compose.override.yml": `services:
+    web:
+        environment:
+            - DEBUG=1
+    db:
+        image: mysql:1.0.0 # impose:warnMajor
+`,
+	}
+	g := groupFromStrs(t, files, "docker-compose.yml", "docker-compose.override.yml")
+
+	lookups := map[string]int{}
+	mu := sync.Mutex{}
+	reg := &registryMock{
+		getImageVersionsFn: func(imageName string) ([]string, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			lookups[imageName]++
+			return []string{"1.0.0", "2.0.0"}, nil
+		},
+	}
+	err := g.UpdateVersions(reg)
+	if err != nil {
+		t.Fatalf("expected no error, got '%v'", err)
+	}
+	if lookups["alpine"] != 1 || lookups["mysql"] != 1 {
+		t.Errorf("expected one lookup per image, got '%v'", lookups)
+	}
+	if !g.HasUpdates() || !g.HasWarnings() || g.HasFailures() {
+		t.Errorf("expected updates and warnings without failures")
+	}
+
+	err = g.WriteToOriginalFile()
+	if err != nil {
+		t.Fatalf("expected no error, got '%v'", err)
+	}
+	for _, p := range g.parsers {
+		b, err := os.ReadFile(p.file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(b), ":2.0.0") {
+			t.Errorf("expected '%v' to be updated, got '%v'", p.file, string(b))
+		}
+	}
+}
+
+func TestGroup_failedLookup(t *testing.T) {
+	files := map[string]string{
+		"a.yml": `services:
+    web:
+        image: alpine:0.1.0
+`,
+		"b.yml": `services:
+    db:
+        image: mysql:1.0.0
+`,
+	}
+	g := groupFromStrs(t, files, "a.yml", "b.yml")
+	reg := &registryMock{
+		getImageVersionsFn: func(imageName string) ([]string, error) {
+			if imageName == "mysql" {
+				return nil, errors.New("not found")
+			}
+			return []string{"1.0.0"}, nil
+		},
+	}
+	err := g.UpdateVersions(reg)
+	if err != nil {
+		t.Fatalf("expected no error, got '%v'", err)
+	}
+	if !g.HasUpdates() || !g.HasFailures() {
+		t.Error("expected updates and failures")
+	}
+}
+
+func TestGroup_writeSummary(t *testing.T) {
+	files := map[string]string{
+		"a.yml": `services:
+    web:
+        image: alpine:0.1.0
+`,
+		"b.yml": `services:
+    db:
+        image: mysql:1.0.0
+`,
+	}
+	g := groupFromStrs(t, files, "a.yml", "b.yml")
+	err := g.UpdateVersions(&registryMock{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &strings.Builder{}
+	err = g.WriteSummary(b)
+	if err != nil {
+		t.Fatalf("expected no error, got '%v'", err)
+	}
+	expected := g.parsers[0].file + `:
+  Changed versions:
+    alpine:0.1.0 => alpine:1.0.0
+
+` + g.parsers[1].file + `:
+  No version changes
+`
+	if b.String() != expected {
+		t.Errorf("expected '%q', got '%q'", expected, b.String())
+	}
+}
+
+func TestGroup_singleFile(t *testing.T) {
+	files := map[string]string{
+		"docker-compose.yml": `services:
+    web:
+        image: alpine:0.1.0
+`,
+	}
+	g := groupFromStrs(t, files, "docker-compose.yml")
+	err := g.UpdateVersions(&registryMock{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := &strings.Builder{}
+	err = g.WriteSummary(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &strings.Builder{}
+	err = g.parsers[0].WriteSummary(expected)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != expected.String() {
+		t.Errorf("expected '%q', got '%q'", expected.String(), b.String())
+	}
+
+	jsonReport := &bytes.Buffer{}
+	err = g.WriteJSONReport(jsonReport)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reports := []*report{}
+	err = json.Unmarshal(jsonReport.Bytes(), &reports)
+	if err != nil {
+		t.Fatalf("expected a JSON array, got '%v'", err)
+	}
+	if len(reports) != 1 || reports[0].File != g.parsers[0].file {
+		t.Errorf("expected the report of the file, got '%v'", jsonReport.String())
+	}
+
+	out := filepath.Join(t.TempDir(), "out.yml")
+	err = g.WriteToFile(out)
+	if err != nil {
+		t.Errorf("expected no error, got '%v'", err)
+	}
+}
+
+func TestGroup_writeJSONReport(t *testing.T) {
+	files := map[string]string{
+		"a.yml": `services:
+    web:
+        image: alpine:0.1.0
+`,
+		"b.yml": `services:
+    db:
+        image: mysql:1.0.0
+`,
+	}
+	g := groupFromStrs(t, files, "a.yml", "b.yml")
+	err := g.UpdateVersions(&registryMock{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &bytes.Buffer{}
+	err = g.WriteJSONReport(b)
+	if err != nil {
+		t.Fatalf("expected no error, got '%v'", err)
+	}
+	reports := []*report{}
+	err = json.Unmarshal(b.Bytes(), &reports)
+	if err != nil {
+		t.Fatalf("expected a JSON array, got '%v'", err)
+	}
+	if len(reports) != 2 || reports[0].File != g.parsers[0].file || reports[1].File != g.parsers[1].file {
+		t.Errorf("expected a report per file, got '%v'", b.String())
+	}
+}
+
+func TestGroup_writeToFile(t *testing.T) {
+	files := map[string]string{
+		"a.yml": "services: {}\n",
+		"b.yml": "services: {}\n",
+	}
+	g := groupFromStrs(t, files, "a.yml", "b.yml")
+	err := g.WriteToFile(filepath.Join(t.TempDir(), "out.yml"))
+	if err == nil {
+		t.Error("expected error for multiple files")
+	}
+}
+
+func TestGroup_addInvalidFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "docker-compose.yml")
+	err := os.WriteFile(file, []byte("invalid"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = NewGroup().Add(file, nil)
+	if err == nil || !strings.HasPrefix(err.Error(), file+": ") {
+		t.Errorf("expected error with file name, got '%v'", err)
+	}
+}
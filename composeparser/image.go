@@ -10,14 +10,34 @@ import (
 )
 
 type image struct {
-	Name        string
-	VersionStr  string
-	Major       int
-	Minor       int
-	Patch       int
+	// Name is the full name of the image including domain and port, e.g.
+	// 'registry.example.com:5000/team/api'.
+	Name       string
+	Domain     string
+	Port       string
+	Path       string
+	VersionStr string
+	Major      int
+	Minor      int
+	Patch      int
+	// Components are all numeric components of the version, e.g. [1 2 3 4]
+	// for '1.2.3.4'. The first three are also stored as Major, Minor and Patch.
+	Components  []int
 	Suffix      string
+	Build       string
+	Digest      string
 	tagFilter   map[string]bool
 	matcherFunc func(version string) bool
+	// excludePrerelease excludes pre-release versions from the update
+	// candidates, even if the image is a pre-release itself.
+	excludePrerelease bool
+	// constraint restricts the versions the image can be updated to.
+	constraint *constraint
+	// calVer is set if the version is a calendar version, e.g. '20230126'.
+	calVer *calVer
+	// scheme is a custom tag scheme that replaces the default matching and
+	// sorting of the versions.
+	scheme *tagScheme
 }
 
 type updateMode int
@@ -30,6 +50,7 @@ const (
 
 type registry interface {
 	GetImageVersions(imageName string) ([]string, error)
+	GetImageDigest(imageName string, tag string) (string, error)
 }
 
 func (m updateMode) String() string {
@@ -41,54 +62,142 @@ func (m updateMode) String() string {
 }
 
 func newImageFromString(str string) (*image, error) {
-	name, version, _ := strings.Cut(str, ":")
-	return newImageFromComponents(name, version)
+	ref, err := parseReference(str)
+	if err != nil {
+		return nil, err
+	}
+	img := newImageFromReference(ref)
+	if ref.tag != "" {
+		img.setVersionFromStr(ref.tag)
+	}
+	img.Digest = ref.digest
+	return img, nil
 }
 
 func newImageFromComponents(name string, version string) (*image, error) {
-	img := &image{
-		tagFilter: map[string]bool{
-			"latest": true,
-		},
-	}
 	if name == "" {
 		return nil, errors.New("image name can not be empty")
 	}
-	img.Name = name
+	ref, err := parseReference(name)
+	if err != nil {
+		return nil, err
+	}
+	img := newImageFromReference(ref)
 	if version != "" {
 		img.setVersionFromStr(version)
 	}
 	return img, nil
 }
 
+func newImageFromReference(ref *reference) *image {
+	return &image{
+		Name:   ref.name(),
+		Domain: ref.domain,
+		Port:   ref.port,
+		Path:   ref.path,
+		tagFilter: map[string]bool{
+			"latest": true,
+		},
+	}
+}
+
 func (i *image) setVersionFromStr(str string) {
 	i.VersionStr = str
+	var ok bool
+	i.Major, i.Minor, i.Patch, i.calVer, ok = parseCalVer(str)
+	if ok {
+		return
+	}
 	version := strings.TrimPrefix(str, "v")
+	version, i.Build, _ = strings.Cut(version, "+")
 	version, i.Suffix, _ = strings.Cut(version, "-")
 
-	verSlice := strings.Split(version, ".")
-	verSliceLen := len(verSlice)
+	i.Components = parseComponents(version)
+	verSliceLen := len(i.Components)
 	if verSliceLen > 0 {
-		i.Major, _ = strconv.Atoi(verSlice[0])
+		i.Major = i.Components[0]
 	}
 	if verSliceLen > 1 {
-		i.Minor, _ = strconv.Atoi(verSlice[1])
+		i.Minor = i.Components[1]
 	}
 	if verSliceLen > 2 {
-		i.Patch, _ = strconv.Atoi(verSlice[2])
+		i.Patch = i.Components[2]
+	}
+}
+
+// parseComponents returns the dot separated numeric components of the
+// version. Components that are no number are 0.
+func parseComponents(version string) []int {
+	verSlice := strings.Split(version, ".")
+	components := make([]int, len(verSlice))
+	for idx, str := range verSlice {
+		components[idx], _ = strconv.Atoi(str)
+	}
+	return components
+}
+
+// versionComponents returns the numeric components of the version that are
+// relevant for the ordering. Major, minor and patch are always included.
+func (i *image) versionComponents() []int {
+	components := []int{i.Major, i.Minor, i.Patch}
+	if len(i.Components) > 3 {
+		components = append(components, i.Components[3:]...)
 	}
+	return components
 }
 
+// compareComponents compares two lists of version components one by one and
+// returns -1, 0 or +1. Missing components are treated as 0.
+func compareComponents(a []int, b []int) int {
+	for idx := 0; idx < len(a) || idx < len(b); idx++ {
+		var aNum, bNum int
+		if idx < len(a) {
+			aNum = a[idx]
+		}
+		if idx < len(b) {
+			bNum = b[idx]
+		}
+		if aNum < bNum {
+			return -1
+		}
+		if aNum > bNum {
+			return 1
+		}
+	}
+	return 0
+}
+
+// getNormalizedName returns the canonical name of the image, e.g. for the
+// image patterns of the config file. Names on Docker Hub are returned without
+// registry host and official images get the 'library/' prefix. Names on other
+// registries keep their registry host.
 func (i *image) getNormalizedName() string {
-	if len(strings.Split(i.Name, "/")) == 1 && i.Name != "" {
-		return "library/" + i.Name
+	if i.Domain != "" && (i.Port != "" || !isDockerHubDomain(i.Domain)) {
+		return i.Name
+	}
+	if i.Path != "" && !strings.Contains(i.Path, "/") {
+		return "library/" + i.Path
+	}
+	return i.Path
+}
+
+// lookupName returns the image name that is passed to the registry. Unlike the
+// normalized name it keeps the registry host and official images don't get
+// the 'library/' prefix, as this is a convention of Docker Hub that is only
+// applied if the image is actually looked up on Docker Hub.
+func (i *image) lookupName() string {
+	if i.Domain != "" {
+		return i.Name
 	}
-	return i.Name
+	return i.Path
+}
+
+func isDockerHubDomain(domain string) bool {
+	return domain == "docker.io" || domain == "index.docker.io" || domain == "registry-1.docker.io"
 }
 
 func (i *image) GetLatestVersion(reg registry, mode updateMode) (*image, error) {
-	imageName := i.getNormalizedName()
-	imageVerisons, err := reg.GetImageVersions(imageName)
+	imageVerisons, err := reg.GetImageVersions(i.lookupName())
 	if err != nil {
 		return nil, err
 	}
@@ -100,11 +209,20 @@ func (i *image) GetLatestVersion(reg registry, mode updateMode) (*image, error)
 			if err != nil {
 				return nil, err
 			}
+			if i.scheme != nil {
+				i.scheme.apply(img)
+			}
+			if i.constraint != nil && !i.constraint.check(img) {
+				continue
+			}
 			imgVersions = append(imgVersions, img)
 		}
 	}
-	sort.Slice(imgVersions, func(i, j int) bool {
-		return imgVersions[i].Less(imgVersions[j])
+	sort.Slice(imgVersions, func(idx, jdx int) bool {
+		if i.scheme != nil {
+			return i.scheme.less(imgVersions[idx], imgVersions[jdx])
+		}
+		return imgVersions[idx].Less(imgVersions[jdx])
 	})
 
 	if len(imgVersions) < 1 {
@@ -114,31 +232,69 @@ func (i *image) GetLatestVersion(reg registry, mode updateMode) (*image, error)
 	return highestImgVer, nil
 }
 
+// setTagScheme sets a custom tag scheme and applies it to the version of the
+// image.
+func (i *image) setTagScheme(scheme *tagScheme) {
+	i.scheme = scheme
+	scheme.apply(i)
+}
+
+// PinDigest sets the digest of the manifest the tag of the image currently
+// points to.
+func (i *image) PinDigest(reg registry) error {
+	digest, err := reg.GetImageDigest(i.lookupName(), i.tag())
+	if err != nil {
+		return err
+	}
+	i.Digest = digest
+	return nil
+}
+
+// tag returns the tag of the image, which defaults to 'latest'.
+func (i *image) tag() string {
+	if i.VersionStr == "" {
+		return "latest"
+	}
+	return i.VersionStr
+}
+
 func (i *image) String() string {
 	str := i.Name
 	if i.VersionStr != "" {
 		str = str + ":" + i.VersionStr
 	}
+	if i.Digest != "" {
+		str = str + "@" + i.Digest
+	}
 	return str
 }
 
+// taggedDigest returns the tag of the image followed by its digest, if any.
+func (i *image) taggedDigest() string {
+	if i.Digest == "" {
+		return i.VersionStr
+	}
+	return i.VersionStr + "@" + i.Digest
+}
+
 func (i *image) Less(comp *image) bool {
 	if comp == nil {
 		return false
 	}
-	if i.Major < comp.Major {
-		return true
+	if c := compareComponents(i.versionComponents(), comp.versionComponents()); c != 0 {
+		return c < 0
 	}
-	if i.Major == comp.Major && i.Minor < comp.Minor {
-		return true
+	if i.calVer != nil && comp.calVer != nil {
+		// e.g. the time in 'RELEASE.2023-01-12T00-00-00Z'
+		return compareNumbers(i.calVer.suffix, comp.calVer.suffix) < 0
 	}
-	if i.Major == comp.Major && i.Minor == comp.Minor && i.Patch < comp.Patch {
-		return true
+	// the build metadata is ignored for the precedence
+	prerelease, variant := splitSuffix(i.Suffix)
+	compPrerelease, compVariant := splitSuffix(comp.Suffix)
+	if c := comparePrerelease(prerelease, compPrerelease); c != 0 {
+		return c < 0
 	}
-	if i.Major == comp.Major && i.Minor == comp.Minor && i.Patch == comp.Patch && i.Suffix < comp.Suffix {
-		return true
-	}
-	return false
+	return variant < compVariant
 }
 
 func (i *image) Compare(comp *image) bool {
@@ -155,6 +311,13 @@ func (i *image) IsSameVersion(comp *image) bool {
 	return i.VersionStr == comp.VersionStr
 }
 
+func (i *image) IsSameDigest(comp *image) bool {
+	if comp == nil {
+		return false
+	}
+	return i.Digest == comp.Digest
+}
+
 func (i *image) IsSameMajor(comp *image) bool {
 	if comp == nil {
 		return false
@@ -180,9 +343,27 @@ func (i *image) matchesScheme(str string) bool {
 	if i.matcherFunc == nil {
 		i.setVersionMatcher(updateMajor)
 	}
+	// the current pre-release stays a candidate, so that an image without a
+	// release yet is kept instead of failing
+	if i.excludePrerelease && isPrerelease(str) && str != i.VersionStr {
+		return false
+	}
 	return !i.tagFilter[str] && i.matcherFunc(str)
 }
 
+// matchesSuffix reports whether the suffix of the version fits the suffix of
+// the image. The variants (e.g. 'alpine' in '1.2.3-alpine') have to be equal.
+// Pre-releases only match if the image is a pre-release itself, so that a
+// release is never updated to a pre-release.
+func (i *image) matchesSuffix(version string) bool {
+	prerelease, variant := splitSuffix(versionSuffix(version))
+	imgPrerelease, imgVariant := splitSuffix(i.Suffix)
+	if variant != imgVariant {
+		return false
+	}
+	return prerelease == "" || imgPrerelease != ""
+}
+
 var re3DigitsSuffix = regexp.MustCompile(`^[0-9]+\.[0-9]+\.[0-9]+.*$`)
 var re2DigitsSuffix = regexp.MustCompile(`^[0-9]+\.[0-9]+.*$`)
 var re1DigitSuffix = regexp.MustCompile(`^[0-9]+.*$`)
@@ -202,36 +383,81 @@ func (i *image) setVersionMatcher(mode updateMode) {
 	}
 	matchVersionV := "v" + matchVersion
 
-	if re3DigitsSuffix.MatchString(i.VersionStr) {
+	if i.scheme != nil && i.scheme.re != nil {
+		i.matcherFunc = func(version string) bool {
+			if !i.scheme.matches(version) {
+				return false
+			}
+			if !i.scheme.hasVersionGroups() || mode == updateMajor {
+				return true
+			}
+			candidate := &image{VersionStr: version}
+			i.scheme.apply(candidate)
+			if mode == updateMinor {
+				return i.IsSameMajor(candidate)
+			}
+			return i.IsSameMinor(candidate)
+		}
+	} else if len(i.Components) > 3 {
+		// versions with more than three components only match versions with
+		// the same number of components, e.g. '1.2.3.4' and '1.2.3.5'
+		hasV := strings.HasPrefix(i.VersionStr, "v")
+		i.matcherFunc = func(version string) bool {
+			if strings.HasPrefix(version, "v") != hasV || !i.matchesSuffix(version) {
+				return false
+			}
+			candidate := &image{}
+			candidate.setVersionFromStr(version)
+			if candidate.calVer != nil || len(candidate.Components) != len(i.Components) {
+				return false
+			}
+			switch mode {
+			case updateMinor:
+				return i.IsSameMajor(candidate)
+			case updatePatch:
+				return i.IsSameMinor(candidate)
+			}
+			return true
+		}
+	} else if i.calVer != nil {
+		// calendar versions stay within the same year (minor) or month (patch)
+		i.matcherFunc = func(version string) bool {
+			year, month, _, calVer, ok := parseCalVer(version)
+			if !ok || !i.calVer.sameScheme(calVer) {
+				return false
+			}
+			switch mode {
+			case updateMinor:
+				return year == i.Major
+			case updatePatch:
+				return year == i.Major && month == i.Minor
+			}
+			return true
+		}
+	} else if re3DigitsSuffix.MatchString(i.VersionStr) {
 		i.matcherFunc = func(version string) bool {
 			// strings.HasSuffix is too inaccurate, we need to compare the exact suffix
-			_, suffix, _ := strings.Cut(version, "-")
-			return re3DigitsSuffix.MatchString(version) && strings.HasPrefix(version, matchVersion) && i.Suffix == suffix
+			return re3DigitsSuffix.MatchString(version) && strings.HasPrefix(version, matchVersion) && i.matchesSuffix(version)
 		}
 	} else if re2DigitsSuffix.MatchString(i.VersionStr) {
 		i.matcherFunc = func(version string) bool {
-			_, suffix, _ := strings.Cut(version, "-")
-			return re2DigitsSuffix.MatchString(version) && strings.HasPrefix(version, matchVersion) && i.Suffix == suffix
+			return re2DigitsSuffix.MatchString(version) && strings.HasPrefix(version, matchVersion) && i.matchesSuffix(version)
 		}
 	} else if re1DigitSuffix.MatchString(i.VersionStr) {
 		i.matcherFunc = func(version string) bool {
-			_, suffix, _ := strings.Cut(version, "-")
-			return re1DigitSuffix.MatchString(version) && i.Suffix == suffix
+			return re1DigitSuffix.MatchString(version) && i.matchesSuffix(version)
 		}
 	} else if reV3DigitsSuffix.MatchString(i.VersionStr) {
 		i.matcherFunc = func(version string) bool {
-			_, suffix, _ := strings.Cut(version, "-")
-			return reV3DigitsSuffix.MatchString(version) && strings.HasPrefix(version, matchVersionV) && i.Suffix == suffix
+			return reV3DigitsSuffix.MatchString(version) && strings.HasPrefix(version, matchVersionV) && i.matchesSuffix(version)
 		}
 	} else if reV2DigitsSuffix.MatchString(i.VersionStr) {
 		i.matcherFunc = func(version string) bool {
-			_, suffix, _ := strings.Cut(version, "-")
-			return reV2DigitsSuffix.MatchString(version) && strings.HasPrefix(version, matchVersionV) && i.Suffix == suffix
+			return reV2DigitsSuffix.MatchString(version) && strings.HasPrefix(version, matchVersionV) && i.matchesSuffix(version)
 		}
 	} else if reV1DigitsSuffix.MatchString(i.VersionStr) {
 		i.matcherFunc = func(version string) bool {
-			_, suffix, _ := strings.Cut(version, "-")
-			return reV1DigitsSuffix.MatchString(version) && i.Suffix == suffix
+			return reV1DigitsSuffix.MatchString(version) && i.matchesSuffix(version)
 		}
 	} else {
 		// Match all fallback
@@ -1,11 +1,13 @@
 package composeparser
 
 import (
+	"reflect"
 	"testing"
 )
 
 type registryMock struct {
 	getImageVersionsFn func(imageName string) ([]string, error)
+	getImageDigestFn   func(imageName string, tag string) (string, error)
 }
 
 func (r *registryMock) GetImageVersions(imageName string) ([]string, error) {
@@ -15,6 +17,13 @@ func (r *registryMock) GetImageVersions(imageName string) ([]string, error) {
 	return []string{"1.0.0"}, nil
 }
 
+func (r *registryMock) GetImageDigest(imageName string, tag string) (string, error) {
+	if r != nil && r.getImageDigestFn != nil {
+		return r.getImageDigestFn(imageName, tag)
+	}
+	return "sha256:" + tag, nil
+}
+
 type versionParts struct {
 	Major      int
 	Minor      int
@@ -56,6 +65,118 @@ func TestNewImageFromString_WithoutVersion(t *testing.T) {
 	assertVersionParts(t, i, expected)
 }
 
+func TestNewImageFromString_WithRegistryPort(t *testing.T) {
+	expected := &versionParts{
+		Major:      2,
+		Minor:      0,
+		Patch:      0,
+		Name:       "registry.example.com:5000/team/api",
+		Suffix:     "",
+		VersionStr: "2.0",
+	}
+	i, err := newImageFromString("registry.example.com:5000/team/api:2.0")
+	if err != nil {
+		t.Fatalf("expected no error, got '%v'", err)
+	}
+	assertVersionParts(t, i, expected)
+}
+
+func TestNewImageFromString_WithRegistryPortWithoutVersion(t *testing.T) {
+	expected := &versionParts{
+		Name: "localhost:5000/app",
+	}
+	i, err := newImageFromString("localhost:5000/app")
+	if err != nil {
+		t.Fatalf("expected no error, got '%v'", err)
+	}
+	assertVersionParts(t, i, expected)
+}
+
+func TestNewImageFromString_ReferenceParts(t *testing.T) {
+	i, err := newImageFromString("registry.example.com:5000/team/api:2.0")
+	if err != nil {
+		t.Fatalf("expected no error, got '%v'", err)
+	}
+	if i.Domain != "registry.example.com" || i.Port != "5000" || i.Path != "team/api" {
+		t.Errorf("expected 'registry.example.com', '5000' and 'team/api', got '%v', '%v' and '%v'", i.Domain, i.Port, i.Path)
+	}
+}
+
+func TestNewImageFromString_Invalid(t *testing.T) {
+	_, err := newImageFromString("Alpine:3.16.3")
+	if err == nil {
+		t.Error("expected error, got nil")
+	}
+}
+
+func TestNewImageFromString_WithDigest(t *testing.T) {
+	tests := []struct {
+		name           string
+		str            string
+		expected       *versionParts
+		expectedDigest string
+	}{
+		{
+			"tag and digest",
+			"alpine:3.16.3@sha256:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa",
+			&versionParts{Major: 3, Minor: 16, Patch: 3, Name: "alpine", VersionStr: "3.16.3"},
+			"sha256:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa",
+		},
+		{
+			"digest without tag",
+			"alpine@sha256:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa",
+			&versionParts{Name: "alpine"},
+			"sha256:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa",
+		},
+		{
+			"registry port, tag and digest",
+			"localhost:5000/app:1.0@sha256:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa",
+			&versionParts{Major: 1, Name: "localhost:5000/app", VersionStr: "1.0"},
+			"sha256:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i, err := newImageFromString(tt.str)
+			if err != nil {
+				t.Fatalf("expected no error, got '%v'", err)
+			}
+			assertVersionParts(t, i, tt.expected)
+			if tt.expectedDigest != i.Digest {
+				t.Errorf("expected digest '%v', got '%v'", tt.expectedDigest, i.Digest)
+			}
+			if tt.str != i.String() {
+				t.Errorf("expected '%v', got '%v'", tt.str, i.String())
+			}
+		})
+	}
+}
+
+func TestPinDigest(t *testing.T) {
+	var imageName, tag string
+	reg := &registryMock{
+		getImageDigestFn: func(name string, t string) (string, error) {
+			imageName, tag = name, t
+			return "sha256:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa", nil
+		},
+	}
+	i, err := newImageFromString("alpine:3.16.3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = i.PinDigest(reg)
+	if err != nil {
+		t.Fatalf("expected no error, got '%v'", err)
+	}
+	if imageName != "alpine" || tag != "3.16.3" {
+		t.Errorf("expected lookup of 'alpine:3.16.3', got '%v:%v'", imageName, tag)
+	}
+	const expected = "alpine:3.16.3@sha256:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+	if expected != i.String() {
+		t.Errorf("expected '%v', got '%v'", expected, i.String())
+	}
+}
+
 func TestNewImageFromComponents_WithoutName(t *testing.T) {
 	_, err := newImageFromComponents("", "1.0.0")
 	if err == nil {
@@ -163,6 +284,48 @@ func TestSetVersionFromStr(t *testing.T) {
 	}
 }
 
+func TestSetVersionFromStr_Components(t *testing.T) {
+	i := &image{}
+	i.setVersionFromStr("v1.2.3.4-suffix")
+	expected := []int{1, 2, 3, 4}
+	if !reflect.DeepEqual(expected, i.Components) {
+		t.Errorf("expected %v, got %v", expected, i.Components)
+	}
+}
+
+func TestSetVersionFromStr_Build(t *testing.T) {
+	i := &image{}
+	i.setVersionFromStr("1.2.3-rc.1+build.5")
+	if i.Suffix != "rc.1" || i.Build != "build.5" {
+		t.Errorf("expected suffix 'rc.1' and build 'build.5', got '%v' and '%v'", i.Suffix, i.Build)
+	}
+}
+
+func TestLookupName(t *testing.T) {
+	tests := []struct {
+		imageName string
+		expected  string
+	}{
+		{"image", "image"},
+		{"some/image", "some/image"},
+		{"library/image", "library/image"},
+		{"docker.io/image", "docker.io/image"},
+		{"ghcr.io/org/app", "ghcr.io/org/app"},
+		{"registry.example.com:5000/team/api", "registry.example.com:5000/team/api"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.imageName, func(t *testing.T) {
+			i, err := newImageFromString(tt.imageName)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if actual := i.lookupName(); actual != tt.expected {
+				t.Errorf("expected '%v', got '%v'", tt.expected, actual)
+			}
+		})
+	}
+}
+
 func TestGetNormalizedName(t *testing.T) {
 	tests := []struct {
 		name      string
@@ -194,11 +357,36 @@ func TestGetNormalizedName(t *testing.T) {
 			"",
 			"",
 		},
+		{
+			"image on other registry",
+			"ghcr.io/org/app",
+			"ghcr.io/org/app",
+		},
+		{
+			"image on registry with port",
+			"registry.example.com:5000/team/api",
+			"registry.example.com:5000/team/api",
+		},
+		{
+			"official image with Docker Hub host",
+			"docker.io/image",
+			"library/image",
+		},
+		{
+			"image with Docker Hub host",
+			"docker.io/some/image",
+			"some/image",
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			i := &image{
-				Name: tt.imageName,
+			i := &image{}
+			if tt.imageName != "" {
+				var err error
+				i, err = newImageFromComponents(tt.imageName, "")
+				if err != nil {
+					t.Fatal(err)
+				}
 			}
 			normImgName := i.getNormalizedName()
 			if tt.expected != normImgName {
@@ -280,6 +468,85 @@ func TestLess(t *testing.T) {
 			},
 			true,
 		},
+		{
+			"fourth component is less",
+			&image{
+				Major:      3,
+				Minor:      11,
+				Patch:      2,
+				Components: []int{3, 11, 2, 1},
+			},
+			&image{
+				Major:      3,
+				Minor:      11,
+				Patch:      2,
+				Components: []int{3, 11, 2, 2},
+			},
+			true,
+		},
+		{
+			"missing fourth component is less",
+			&image{
+				Major: 1,
+				Minor: 2,
+				Patch: 3,
+			},
+			&image{
+				Major:      1,
+				Minor:      2,
+				Patch:      3,
+				Components: []int{1, 2, 3, 4},
+			},
+			true,
+		},
+		{
+			"numeric pre-release identifier is less",
+			&image{
+				Major:  1,
+				Minor:  2,
+				Patch:  3,
+				Suffix: "rc.2",
+			},
+			&image{
+				Major:  1,
+				Minor:  2,
+				Patch:  3,
+				Suffix: "rc.10",
+			},
+			true,
+		},
+		{
+			"pre-release is less than release",
+			&image{
+				Major:  1,
+				Minor:  2,
+				Patch:  3,
+				Suffix: "rc.10",
+			},
+			&image{
+				Major:  1,
+				Minor:  2,
+				Patch:  3,
+				Suffix: "",
+			},
+			true,
+		},
+		{
+			"release is not less than pre-release",
+			&image{
+				Major:  1,
+				Minor:  2,
+				Patch:  3,
+				Suffix: "",
+			},
+			&image{
+				Major:  1,
+				Minor:  2,
+				Patch:  3,
+				Suffix: "beta",
+			},
+			false,
+		},
 		{
 			"equal",
 			&image{
@@ -418,6 +685,85 @@ func TestGetLatestVersion(t *testing.T) {
 				expectVersion(t, latestImg, err, "some/image:3.0.0-suffix")
 			},
 		},
+		{
+			"mode updateMajor with four components",
+			"some/image:3.11.2.1",
+			updateMajor,
+			[]string{
+				"3.11.2.10",
+				"3.11.2.9",
+				"3.11.3",
+				"3.11.2.1.5",
+			},
+			func(t *testing.T, latestImg *image, err error) {
+				expectVersion(t, latestImg, err, "some/image:3.11.2.10")
+			},
+		},
+		{
+			"mode updatePatch with four components",
+			"some/image:1.2.3.4",
+			updatePatch,
+			[]string{
+				"1.2.3.5",
+				"1.2.4.0",
+				"1.3.0.0",
+				"1.20.0.0",
+			},
+			func(t *testing.T, latestImg *image, err error) {
+				expectVersion(t, latestImg, err, "some/image:1.2.4.0")
+			},
+		},
+		{
+			"mode updateMajor with pre-releases",
+			"some/image:1.2.3-rc.2",
+			updateMajor,
+			[]string{
+				"1.2.3-rc.2",
+				"1.2.3-rc.10",
+				"1.2.3-beta.5",
+			},
+			func(t *testing.T, latestImg *image, err error) {
+				expectVersion(t, latestImg, err, "some/image:1.2.3-rc.10")
+			},
+		},
+		{
+			"mode updateMajor with release after pre-releases",
+			"some/image:1.2.3-rc.2",
+			updateMajor,
+			[]string{
+				"1.2.3-rc.10",
+				"1.2.3",
+				"1.2.3-alpine",
+			},
+			func(t *testing.T, latestImg *image, err error) {
+				expectVersion(t, latestImg, err, "some/image:1.2.3")
+			},
+		},
+		{
+			"mode updateMajor with pre-release of variant",
+			"some/image:1.2.3-rc.1-alpine",
+			updateMajor,
+			[]string{
+				"1.2.3-rc.2",
+				"1.2.3-rc.2-alpine",
+				"1.2.3-alpine",
+			},
+			func(t *testing.T, latestImg *image, err error) {
+				expectVersion(t, latestImg, err, "some/image:1.2.3-alpine")
+			},
+		},
+		{
+			"mode updateMajor does not update release to pre-release",
+			"some/image:1.2.3",
+			updateMajor,
+			[]string{
+				"1.2.3",
+				"1.3.0-beta.1",
+			},
+			func(t *testing.T, latestImg *image, err error) {
+				expectVersion(t, latestImg, err, "some/image:1.2.3")
+			},
+		},
 		{
 			"mode updateMajor with no matching prefix",
 			"some/image:v1.0.0",
@@ -471,6 +817,52 @@ func TestMatchesScheme_TagFilter(t *testing.T) {
 	}
 }
 
+func TestMatchesScheme_ExcludePrerelease(t *testing.T) {
+	tests := []struct {
+		version  string
+		expected bool
+	}{
+		{"1.3.0", true},
+		{"1.3.0-rc.1", false},
+		{"1.3.0-beta", false},
+		{"1.3.0+build.5", true},
+		{"1.2.3-rc.1", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			i, err := newImageFromString("some/image:1.2.3-rc.1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			i.excludePrerelease = true
+			actual := i.matchesScheme(tt.version)
+			if tt.expected != actual {
+				t.Errorf("expected '%v', got '%v'", tt.expected, actual)
+			}
+		})
+	}
+}
+
+func TestGetLatestVersion_excludePrereleaseWithoutRelease(t *testing.T) {
+	i, err := newImageFromString("app:1.2.3-rc.2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	i.excludePrerelease = true
+	reg := &registryMock{
+		getImageVersionsFn: func(imageName string) ([]string, error) {
+			return []string{"1.2.3-rc.1", "1.2.3-rc.2", "1.2.3-rc.3"}, nil
+		},
+	}
+	latest, err := i.GetLatestVersion(reg, updateMajor)
+	if err != nil {
+		t.Fatalf("expected no error, got '%v'", err)
+	}
+	if latest.String() != "app:1.2.3-rc.2" {
+		t.Errorf("expected '%v', got '%v'", "app:1.2.3-rc.2", latest)
+	}
+}
+
 func TestMatchesScheme_MatcherFuncIsSet(t *testing.T) {
 	i := &image{}
 	i.matchesScheme("")
@@ -0,0 +1,282 @@
+package composeparser
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// environment provides the variables for the interpolation of image
+// references. Following Docker Compose, variables of the process environment
+// take precedence over the variables of the env files (by default the '.env'
+// file), later env files take precedence over earlier ones.
+type environment struct {
+	lookupEnv func(name string) (string, bool)
+	envFiles  []*envFile
+}
+
+func newEnvironment(envFiles ...*envFile) *environment {
+	env := &environment{
+		lookupEnv: os.LookupEnv,
+	}
+	for _, e := range envFiles {
+		if e != nil {
+			env.envFiles = append(env.envFiles, e)
+		}
+	}
+	return env
+}
+
+type segmentOrigin int
+
+const (
+	// originLiteral is text outside of a variable reference
+	originLiteral segmentOrigin = iota
+	// originDefault is the default value of a reference, e.g. '14.5' in
+	// '${PG_VERSION:-14.5}'
+	originDefault
+	// originEnv is a variable of the process environment
+	originEnv
+	// originEnvFile is a variable of an env file
+	originEnvFile
+)
+
+// imageExpression is an image value with variable references, e.g.
+// 'postgres:${PG_VERSION:-14.5}' or '${REGISTRY}/api:${API_TAG}'. The value
+// is split into segments of literal text and references. When the image is
+// updated, the new tag is written back to the segment it is taken from.
+type imageExpression struct {
+	env      *environment
+	segments []*exprSegment
+}
+
+type exprSegment struct {
+	origin segmentOrigin
+	// value is the resolved value of the segment
+	value string
+	// name, operator and argument of a reference, e.g. 'PG_VERSION', ':-'
+	// and '14.5' for '${PG_VERSION:-14.5}'
+	name   string
+	op     string
+	arg    string
+	braces bool
+	// envFile is the file the variable is defined in
+	envFile *envFile
+}
+
+// interpolationOperators are checked in order, so longer operators have to
+// come before their prefixes.
+var interpolationOperators = []string{":-", ":?", "-", "?"}
+
+// newImageExpression parses the image value and resolves its references. The
+// following forms are supported: '$VAR', '${VAR}', '${VAR:-default}' (default
+// if unset or empty), '${VAR-default}' (default if unset), '${VAR:?error}'
+// (error if unset or empty) and '${VAR?error}' (error if unset). '$$' is a
+// literal '$'. Unset variables without default are replaced by an empty
+// string.
+func newImageExpression(str string, env *environment) (*imageExpression, error) {
+	e := &imageExpression{env: env}
+	literal := &strings.Builder{}
+	flushLiteral := func() {
+		if literal.Len() > 0 {
+			e.segments = append(e.segments, &exprSegment{origin: originLiteral, value: literal.String()})
+			literal.Reset()
+		}
+	}
+	for idx := 0; idx < len(str); idx++ {
+		if str[idx] != '$' {
+			literal.WriteByte(str[idx])
+			continue
+		}
+		if idx+1 < len(str) && str[idx+1] == '$' {
+			literal.WriteByte('$')
+			idx++
+			continue
+		}
+		seg, n, err := parseVariableReference(str[idx:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid interpolation format in '%v': %v", str, err)
+		}
+		flushLiteral()
+		err = e.resolve(seg)
+		if err != nil {
+			return nil, err
+		}
+		e.segments = append(e.segments, seg)
+		idx += n - 1
+	}
+	flushLiteral()
+	return e, nil
+}
+
+// parseVariableReference parses the variable reference at the beginning of
+// the string and returns it together with its length.
+func parseVariableReference(str string) (*exprSegment, int, error) {
+	if !strings.HasPrefix(str, "${") {
+		n := 1
+		for n < len(str) && isNameChar(str[n], n == 1) {
+			n++
+		}
+		if n == 1 {
+			return nil, 0, fmt.Errorf("missing variable name")
+		}
+		return &exprSegment{name: str[1:n]}, n, nil
+	}
+	end := strings.Index(str, "}")
+	if end < 0 {
+		return nil, 0, fmt.Errorf("missing '}'")
+	}
+	inner := str[2:end]
+	n := 0
+	for n < len(inner) && isNameChar(inner[n], n == 0) {
+		n++
+	}
+	if n == 0 {
+		return nil, 0, fmt.Errorf("missing variable name")
+	}
+	seg := &exprSegment{name: inner[:n], braces: true}
+	rest := inner[n:]
+	if rest != "" {
+		for _, op := range interpolationOperators {
+			if strings.HasPrefix(rest, op) {
+				seg.op = op
+				seg.arg = rest[len(op):]
+				break
+			}
+		}
+		if seg.op == "" {
+			return nil, 0, fmt.Errorf("unsupported reference '%v'", str[:end+1])
+		}
+	}
+	return seg, end + 1, nil
+}
+
+func isNameChar(c byte, first bool) bool {
+	return c == '_' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || !first && c >= '0' && c <= '9'
+}
+
+// resolve sets the value and the origin of the reference.
+func (e *imageExpression) resolve(seg *exprSegment) error {
+	value, origin, envFile, set := e.lookup(seg.name)
+	seg.value, seg.origin, seg.envFile = value, origin, envFile
+	switch seg.op {
+	case ":-":
+		if !set || value == "" {
+			seg.value, seg.origin = seg.arg, originDefault
+		}
+	case "-":
+		if !set {
+			seg.value, seg.origin = seg.arg, originDefault
+		}
+	case ":?":
+		if !set || value == "" {
+			return fmt.Errorf("required variable '%v' is missing a value: %v", seg.name, seg.arg)
+		}
+	case "?":
+		if !set {
+			return fmt.Errorf("required variable '%v' is missing a value: %v", seg.name, seg.arg)
+		}
+	}
+	return nil
+}
+
+func (e *imageExpression) lookup(name string) (string, segmentOrigin, *envFile, bool) {
+	if e.env == nil {
+		return "", originEnv, nil, false
+	}
+	if e.env.lookupEnv != nil {
+		if value, ok := e.env.lookupEnv(name); ok {
+			return value, originEnv, nil, true
+		}
+	}
+	for idx := len(e.env.envFiles) - 1; idx >= 0; idx-- {
+		if value, ok := e.env.envFiles[idx].lookup(name); ok {
+			return value, originEnvFile, e.env.envFiles[idx], true
+		}
+	}
+	return "", originEnv, nil, false
+}
+
+// tagVariable returns the variable of an env file the tag is taken from
+// together with the env file, or nil if the tag is not taken from an env file.
+func (e *imageExpression) tagVariable() (string, *envFile) {
+	if len(e.segments) == 0 {
+		return "", nil
+	}
+	seg := e.segments[len(e.segments)-1]
+	if seg.origin != originEnvFile {
+		return "", nil
+	}
+	return seg.name, seg.envFile
+}
+
+// resolved returns the image value with all references replaced.
+func (e *imageExpression) resolved() string {
+	b := &strings.Builder{}
+	for _, seg := range e.segments {
+		b.WriteString(seg.value)
+	}
+	return b.String()
+}
+
+// setImage writes the latest image back to the expression. The tag (and the
+// digest) has to be part of the last segment: a literal tag or the default
+// value of a reference is replaced in the expression, a variable of an env
+// file is updated there. A literal variant suffix behind the last reference
+// (e.g. '-alpine' in '${PG_VERSION}-alpine') is kept if it is unchanged. The
+// other parts of the image can not change.
+func (e *imageExpression) setImage(current *image, latest *image) error {
+	if len(e.segments) == 0 {
+		return fmt.Errorf("empty image expression")
+	}
+	resolved, latestStr := e.resolved(), latest.String()
+	// tagStart is the position of the tag or, if there is none, the position
+	// where the tag is added
+	tagStart := len(current.Name)
+	if current.VersionStr != "" && strings.HasPrefix(resolved[tagStart:], ":") {
+		tagStart++
+	}
+	seg := e.segments[len(e.segments)-1]
+	segStart := len(resolved) - len(seg.value)
+	suffix := ""
+	if segStart > tagStart && seg.origin == originLiteral && strings.HasPrefix(seg.value, "-") && len(e.segments) > 1 {
+		suffix = seg.value
+		seg = e.segments[len(e.segments)-2]
+		segStart -= len(seg.value)
+	}
+	segEnd := len(latestStr) - len(suffix)
+	if segStart > tagStart || !strings.HasSuffix(latestStr, suffix) || segEnd < segStart || latestStr[:segStart] != resolved[:segStart] {
+		return fmt.Errorf("the tag of '%v' is composed of several parts and can not be updated", e)
+	}
+	value := latestStr[segStart:segEnd]
+	switch seg.origin {
+	case originEnv:
+		return fmt.Errorf("the tag of '%v' is taken from the environment variable '%v' and can not be updated", e, seg.name)
+	case originEnvFile:
+		err := seg.envFile.set(seg.name, value)
+		if err != nil {
+			return err
+		}
+	case originDefault:
+		seg.arg = value
+	}
+	seg.value = value
+	return nil
+}
+
+// String returns the expression with the current values of the literals and
+// the default values.
+func (e *imageExpression) String() string {
+	b := &strings.Builder{}
+	for _, seg := range e.segments {
+		switch {
+		case seg.name == "":
+			b.WriteString(strings.ReplaceAll(seg.value, "$", "$$"))
+		case seg.braces:
+			fmt.Fprintf(b, "${%s%s%s}", seg.name, seg.op, seg.arg)
+		default:
+			fmt.Fprintf(b, "$%s", seg.name)
+		}
+	}
+	return b.String()
+}
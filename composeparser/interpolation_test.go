@@ -0,0 +1,133 @@
+package composeparser
+
+import (
+	"testing"
+)
+
+func testEnvironment(env map[string]string, dotenv string) *environment {
+	e := &environment{
+		lookupEnv: func(name string) (string, bool) {
+			value, ok := env[name]
+			return value, ok
+		},
+	}
+	if dotenv != "" {
+		f := &envFile{file: ".env"}
+		f.parse([]byte(dotenv))
+		e.envFiles = []*envFile{f}
+	}
+	return e
+}
+
+func TestNewImageExpression(t *testing.T) {
+	env := testEnvironment(map[string]string{
+		"REGISTRY": "registry.example.com",
+		"EMPTY":    "",
+	}, "API_TAG=1.2.3\nPG_VERSION=15.1\n")
+	tests := []struct {
+		expr     string
+		expected string
+		err      bool
+	}{
+		{"postgres:${PG_VERSION}", "postgres:15.1", false},
+		{"postgres:$PG_VERSION", "postgres:15.1", false},
+		{"postgres:${PG_VERSION:-14.5}", "postgres:15.1", false},
+		{"postgres:${UNSET:-14.5}", "postgres:14.5", false},
+		{"postgres:${EMPTY:-14.5}", "postgres:14.5", false},
+		{"postgres:${EMPTY-14.5}", "postgres:", false},
+		{"postgres:${UNSET-14.5}", "postgres:14.5", false},
+		{"${REGISTRY}/api:${API_TAG}", "registry.example.com/api:1.2.3", false},
+		{"$REGISTRY/api:1.0", "registry.example.com/api:1.0", false},
+		{"postgres:${UNSET}", "postgres:", false},
+		{"postgres:$${PG_VERSION}", "postgres:${PG_VERSION}", false},
+		{"postgres:${PG_VERSION:?must be set}", "postgres:15.1", false},
+		{"postgres:${UNSET:?must be set}", "", true},
+		{"postgres:${EMPTY:?must be set}", "", true},
+		{"postgres:${EMPTY?must be set}", "postgres:", false},
+		{"postgres:${UNSET?must be set}", "", true},
+		{"postgres:${PG_VERSION", "", true},
+		{"postgres:${PG_VERSION:+1}", "", true},
+		{"postgres:$", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			e, err := newImageExpression(tt.expr, env)
+			if tt.err {
+				if err == nil {
+					t.Error("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error, got '%v'", err)
+			}
+			if e.resolved() != tt.expected {
+				t.Errorf("expected '%v', got '%v'", tt.expected, e.resolved())
+			}
+			if e.String() != tt.expr {
+				t.Errorf("expected '%v', got '%v'", tt.expr, e.String())
+			}
+		})
+	}
+}
+
+func TestImageExpression_setImage(t *testing.T) {
+	tests := []struct {
+		expr           string
+		latest         string
+		expected       string
+		expectedDotenv string
+		err            bool
+	}{
+		{"postgres:${PG_VERSION:-14.5}", "postgres:14.6", "postgres:${PG_VERSION:-14.6}", "", false},
+		{"postgres:${PG_TAG}", "postgres:15.2", "postgres:${PG_TAG}", "PG_TAG=15.2\nAPI_TAG=1.2.3 # api\n", false},
+		{"${REGISTRY}/api:${API_TAG}", "registry.example.com/api:1.3.0", "${REGISTRY}/api:${API_TAG}", "PG_TAG=15.1\nAPI_TAG=1.3.0 # api\n", false},
+		{"${REGISTRY}/api:1.2.3", "registry.example.com/api:1.3.0", "${REGISTRY}/api:1.3.0", "", false},
+		{"${IMAGE:-nginx:1.0.0}", "nginx:1.1.0", "${IMAGE:-nginx:1.1.0}", "", false},
+		{"postgres:${PG_VERSION:-14.5}-alpine", "postgres:14.6-alpine", "postgres:${PG_VERSION:-14.6}-alpine", "", false},
+		{"postgres:${PG_TAG}-alpine", "postgres:15.2-alpine", "postgres:${PG_TAG}-alpine", "PG_TAG=15.2\nAPI_TAG=1.2.3 # api\n", false},
+		{"postgres:${PG_VERSION:-14.5}-alpine", "postgres:14.6-bullseye", "", "", true},
+		{"postgres:${MAJOR:-14}.5", "postgres:15.0", "", "", true},
+		{"postgres:${MAJOR:-14}.5", "postgres:15.5", "", "", true},
+		{"api:${ENV_TAG}", "api:2.0.0", "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			env := testEnvironment(map[string]string{
+				"REGISTRY": "registry.example.com",
+				"ENV_TAG":  "1.0.0",
+			}, "PG_TAG=15.1\nAPI_TAG=1.2.3 # api\n")
+			e, err := newImageExpression(tt.expr, env)
+			if err != nil {
+				t.Fatal(err)
+			}
+			current, err := newImageFromString(e.resolved())
+			if err != nil {
+				t.Fatal(err)
+			}
+			latest, err := newImageFromString(tt.latest)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = e.setImage(current, latest)
+			if tt.err {
+				if err == nil {
+					t.Error("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error, got '%v'", err)
+			}
+			if e.String() != tt.expected {
+				t.Errorf("expected '%v', got '%v'", tt.expected, e.String())
+			}
+			if e.resolved() != tt.latest {
+				t.Errorf("expected '%v', got '%v'", tt.latest, e.resolved())
+			}
+			if tt.expectedDotenv != "" && string(env.envFiles[0].marshal("")) != tt.expectedDotenv {
+				t.Errorf("expected '%q', got '%q'", tt.expectedDotenv, string(env.envFiles[0].marshal("")))
+			}
+		})
+	}
+}
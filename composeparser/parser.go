@@ -5,16 +5,63 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 	"strings"
 
+	"git.larswegmann.de/lars/impose/config"
 	"golang.org/x/sync/errgroup"
 	"gopkg.in/yaml.v3"
 )
 
+// Options configures the behaviour of the parser.
+type Options struct {
+	// FailFast aborts the update with an error as soon as the version lookup
+	// of a service fails. Otherwise the error is recorded for the service and
+	// the remaining services are updated nonetheless.
+	FailFast bool
+	// FollowBuild also updates the FROM instructions of the Dockerfiles
+	// referenced by the build sections of the services.
+	FollowBuild bool
+	// Dockerfile parses the file as Dockerfile instead of Docker Compose
+	// file. Files with a common Dockerfile name are always parsed as
+	// Dockerfile.
+	Dockerfile bool
+	// Digest pins the images to the digest of their latest version, e.g.
+	// 'alpine:3.16.3@sha256:...'. Images that are already pinned to a digest
+	// are always updated with a digest.
+	Digest bool
+	// NoPrerelease excludes pre-release versions (e.g. '1.2.3-rc.1') from the
+	// update candidates of all images.
+	NoPrerelease bool
+	// Config holds the policies of the config file, which are merged with the
+	// inline annotations of the services.
+	Config *config.Config
+	// Reformat writes the whole Docker Compose file in the format of the YAML
+	// encoder. Otherwise only the changed images are replaced in the original
+	// content.
+	Reformat bool
+	// LineEndings of the written files: 'auto' (default) keeps the line
+	// endings of the original file, 'lf' or 'crlf' convert them. A UTF-8 byte
+	// order mark is always kept.
+	LineEndings string
+	// EnvFiles are read instead of the '.env' file of the project directory
+	// for the interpolation of image references, later files take precedence
+	// (like the '--env-file' flag of Docker Compose).
+	EnvFiles []string
+	// envFiles is shared by the parsers of a group, see envFileCache
+	envFiles *envFileCache
+}
+
 type parser struct {
 	file        string
+	opts        Options
+	source      []byte
+	format      fileFormat
+	env         *environment
 	yamlContent yaml.Node
 	services    []*service
+	dockerfile  *dockerfile
+	dockerfiles []*dockerfile
 }
 
 type service struct {
@@ -22,10 +69,28 @@ type service struct {
 	currentImage *image
 	latestImage  *image
 	imageNode    *yaml.Node
-	options      *serviceOptions
+	imageValue   string
+	expr         *imageExpression
+	// alias is the service that updates the image node shared with this
+	// service, e.g. via 'image: *python'
+	alias *service
+	// dockerfile is the followed Dockerfile the image of a build stage is
+	// taken from
+	dockerfile string
+	options    *serviceOptions
+	skipped    bool
+	err        error
+}
+
+type keyNotFoundError struct {
+	key string
 }
 
-func NewParser(file string) (*parser, error) {
+func (e *keyNotFoundError) Error() string {
+	return fmt.Sprintf("no key '%v' found in YAML", e.key)
+}
+
+func NewParser(file string, opts *Options) (*parser, error) {
 	if file == "" {
 		return nil, errors.New("file must be set")
 	}
@@ -33,116 +98,479 @@ func NewParser(file string) (*parser, error) {
 	p := &parser{
 		file: file,
 	}
+	if opts != nil {
+		p.opts = *opts
+	}
+	err := validateLineEndings(p.opts.LineEndings)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.opts.Dockerfile || isDockerfileName(file) {
+		p.dockerfile, err = newDockerfile(file, "")
+		if err != nil {
+			return p, err
+		}
+		p.applyConfig()
+		return p, nil
+	}
+
+	p.env, err = p.loadEnvironment(file)
+	if err != nil {
+		return nil, err
+	}
 
 	f, err := os.Open(file)
 	if err != nil {
 		return nil, err
 	}
+	defer f.Close()
 
 	err = p.parse(f)
+	if err != nil {
+		return p, err
+	}
+	p.applyConfig()
+	return p, nil
+}
+
+// loadEnvironment loads the env files for the interpolation of image
+// references: the files given by the options or the '.env' file in the
+// directory of the Docker Compose file, if it exists.
+func (p *parser) loadEnvironment(file string) (*environment, error) {
+	cache := p.opts.envFiles
+	if cache == nil {
+		cache = &envFileCache{}
+	}
+	if len(p.opts.EnvFiles) == 0 {
+		dotenv, err := cache.load(filepath.Join(filepath.Dir(file), ".env"))
+		if err != nil {
+			return nil, err
+		}
+		return newEnvironment(dotenv), nil
+	}
+	envFiles := []*envFile{}
+	for _, f := range p.opts.EnvFiles {
+		e, err := cache.load(f)
+		if err != nil {
+			return nil, err
+		}
+		if e == nil {
+			return nil, fmt.Errorf("env file '%v' not found", f)
+		}
+		envFiles = append(envFiles, e)
+	}
+	return newEnvironment(envFiles...), nil
+}
 
-	return p, err
+// applyConfig merges the policies of the config file into the options of all
+// services. The policies are looked up by the service name and by the image
+// name as written and in its normalized form (e.g. 'library/postgres').
+func (p *parser) applyConfig() {
+	if p.opts.Config == nil {
+		return
+	}
+	for _, s := range p.allServices() {
+		// services that share the image of another service share its options
+		if s.skipped || s.alias != nil {
+			continue
+		}
+		policy := p.opts.Config.Policy(s.name, s.currentImage.Name, s.currentImage.getNormalizedName())
+		s.options.applyPolicy(policy)
+	}
 }
 
+// UpdateVersions looks up the latest versions of all services. Unless the
+// parser is in fail fast mode, lookup errors are recorded per service and
+// services whose lookup succeeded are updated nonetheless.
 func (p *parser) UpdateVersions(reg registry) error {
 	g := &errgroup.Group{}
-	for i := range p.services {
+	services := p.allServices()
+	for i := range services {
 		idx := i
-		g.Go(func() (err error) {
-			s := p.services[idx]
-			if s.options.ignore {
-				return
-			}
-			mode := updateMajor
-			if s.options.onlyMinor {
-				mode = updateMinor
+		g.Go(func() error {
+			s := services[idx]
+			if s.options.ignore || s.skipped || s.alias != nil {
+				return nil
 			}
-			if s.options.onlyPatch {
-				mode = updatePatch
+			// the error of an invalid image reference is already recorded
+			if s.err == nil {
+				s.err = p.updateService(s, reg)
 			}
-			s.latestImage, err = s.currentImage.GetLatestVersion(reg, mode)
-			if err != nil {
-				return
+			if s.err != nil && p.opts.FailFast {
+				return s.err
 			}
-			s.imageNode.Value = s.latestImage.String()
-			return
+			return nil
 		})
 	}
-	return g.Wait()
+	err := g.Wait()
+	for _, s := range services {
+		if s.alias != nil {
+			s.latestImage, s.err = s.alias.latestImage, s.alias.err
+		}
+	}
+	return err
+}
+
+// updateService looks up the latest version of the service and updates its
+// image node.
+func (p *parser) updateService(s *service, reg registry) error {
+	img := s.currentImage
+	img.excludePrerelease = p.opts.NoPrerelease || s.options.noPrerelease
+	if s.options.constraint != "" {
+		c, err := newConstraint(s.options.constraint)
+		if err != nil {
+			return err
+		}
+		img.constraint = c
+	}
+	if s.options.match != "" || s.options.sort != "" {
+		scheme, err := newTagScheme(s.options.match, s.options.sort)
+		if err != nil {
+			return err
+		}
+		img.setTagScheme(scheme)
+	}
+	latestImage, err := img.GetLatestVersion(reg, s.updateMode())
+	if err != nil {
+		return err
+	}
+	if p.opts.Digest || s.options.digest || img.Digest != "" {
+		err = latestImage.PinDigest(reg)
+		if err != nil {
+			return err
+		}
+	}
+	s.latestImage = latestImage
+	if s.imageNode == nil {
+		return nil
+	}
+	if s.expr == nil {
+		s.imageNode.Value = s.latestImage.String()
+		return nil
+	}
+	if s.hasChanged() {
+		err = s.expr.setImage(s.currentImage, s.latestImage)
+		if err != nil {
+			s.latestImage = nil
+			return err
+		}
+		s.imageNode.Value = s.expr.String()
+	}
+	return nil
 }
 
+// WriteToStdout writes the Docker Compose file (or Dockerfile) to std out.
+// Changed env files and followed Dockerfiles are not written, see
+// WriteToOriginalFile.
 func (p *parser) WriteToStdout() error {
-	b, err := p.marshalYaml()
+	b, err := p.marshal()
 	if err != nil {
 		return err
 	}
-	_, err = fmt.Println(string(b))
-	return err
+	// the content is followed by an empty line in the line endings of the
+	// file
+	_, err = os.Stdout.Write(append(b, p.lineBreak()...))
+	if err != nil {
+		return err
+	}
+	p.reportUnwrittenFiles(os.Stderr)
+	return nil
 }
 
+// WriteToOriginalFile writes the Docker Compose file (or Dockerfile) back to
+// its original file. Changed env files and Dockerfiles of followed build
+// sections are written back to their original files as well, which is only
+// done in this mode, so that writing the result to another file or std out
+// leaves the project untouched.
 func (p *parser) WriteToOriginalFile() error {
 	if p.file == "" {
 		return errors.New("no original file given")
 	}
-	return p.WriteToFile(p.file)
+	err := p.writeFile(p.file)
+	if err != nil {
+		return err
+	}
+	for _, d := range p.dockerfiles {
+		err = d.writeIfChanged(p.opts.LineEndings)
+		if err != nil {
+			return err
+		}
+	}
+	if p.env != nil {
+		for _, e := range p.env.envFiles {
+			err = e.writeIfChanged(p.opts.LineEndings)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
 }
 
+// WriteToFile writes the Docker Compose file (or Dockerfile) to the given
+// file. Changed env files and followed Dockerfiles are not written, see
+// WriteToOriginalFile.
 func (p *parser) WriteToFile(file string) error {
-	b, err := p.marshalYaml()
+	err := p.writeFile(file)
 	if err != nil {
 		return err
 	}
-	err = os.WriteFile(file, b, 0644)
-	return err
+	p.reportUnwrittenFiles(os.Stderr)
+	return nil
+}
+
+// lineBreak returns the line break of the written file.
+func (p *parser) lineBreak() string {
+	if p.dockerfile != nil {
+		return p.dockerfile.format.lineBreak(p.opts.LineEndings)
+	}
+	return p.format.lineBreak(p.opts.LineEndings)
+}
+
+func (p *parser) writeFile(file string) error {
+	b, err := p.marshal()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, b, 0644)
+}
+
+// unwrittenFiles returns the changed files besides the Docker Compose file,
+// which are only written by WriteToOriginalFile.
+func (p *parser) unwrittenFiles() []string {
+	files := []string{}
+	for _, d := range p.dockerfiles {
+		if d.hasChanges() {
+			files = append(files, d.file)
+		}
+	}
+	if p.env != nil {
+		for _, e := range p.env.envFiles {
+			if e.hasChanges() {
+				files = append(files, e.file)
+			}
+		}
+	}
+	return files
+}
+
+// reportUnwrittenFiles tells that the changes of the unwritten files are
+// missing in the output, although they are listed in the summary.
+func (p *parser) reportUnwrittenFiles(w io.Writer) {
+	for _, file := range p.unwrittenFiles() {
+		fmt.Fprintf(w, "Changes to '%v' were not written, they are only written when updating the original file\n", file)
+	}
 }
 
 func (p *parser) PrintSummary() {
+	p.WriteSummary(os.Stdout)
+}
+
+// WriteSummary writes a human readable summary of the version changes.
+func (p *parser) WriteSummary(w io.Writer) error {
+	changed := p.changedServices()
+	warnings := p.warningServices()
+	failed := p.failedServices()
+	skipped := p.skippedServices()
+
+	b := &strings.Builder{}
+	if len(changed) > 0 {
+		fmt.Fprintln(b, "Changed versions:")
+		writeServices(b, changed)
+		if len(warnings) > 0 {
+			fmt.Fprintln(b)
+			fmt.Fprintln(b, "Warnings (requires attention):")
+			writeServices(b, warnings)
+		}
+	} else {
+		fmt.Fprintln(b, "No version changes")
+	}
+	if len(failed) > 0 {
+		fmt.Fprintln(b)
+		fmt.Fprintln(b, "Failed version lookups:")
+		writeFailedServices(b, failed)
+	}
+	if len(skipped) > 0 {
+		fmt.Fprintln(b)
+		fmt.Fprintln(b, "Skipped services (no image):")
+		for _, s := range skipped {
+			fmt.Fprintf(b, "  %s\n", s.name)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// HasUpdates reports whether the version of any service has changed.
+func (p *parser) HasUpdates() bool {
+	return len(p.changedServices()) > 0
+}
+
+// HasWarnings reports whether the version of any service has changed in a way
+// that triggers one of its warning annotations.
+func (p *parser) HasWarnings() bool {
+	return len(p.warningServices()) > 0
+}
+
+// HasFailures reports whether the version lookup of any service failed.
+func (p *parser) HasFailures() bool {
+	return len(p.failedServices()) > 0
+}
+
+func (p *parser) changedServices() []*service {
 	changed := []*service{}
-	padChanged := 0
+	for _, s := range p.allServices() {
+		if !s.options.ignore && s.hasChanged() {
+			changed = append(changed, s)
+		}
+	}
+	return changed
+}
+
+func (p *parser) warningServices() []*service {
 	warnings := []*service{}
-	padWarnings := 0
+	for _, s := range p.allServices() {
+		if !s.options.ignore && len(s.firedWarnings()) > 0 {
+			warnings = append(warnings, s)
+		}
+	}
+	return warnings
+}
 
-	for _, s := range p.services {
-		if !s.options.ignore && !s.currentImage.IsSameVersion(s.latestImage) {
-			padLen := len(s.currentImage.String())
-			if padChanged < padLen {
-				padChanged = padLen
-			}
-			changed = append(changed, s)
+func (p *parser) failedServices() []*service {
+	failed := []*service{}
+	for _, s := range p.allServices() {
+		if s.err != nil {
+			failed = append(failed, s)
 		}
 	}
+	return failed
+}
 
+func (p *parser) skippedServices() []*service {
+	skipped := []*service{}
 	for _, s := range p.services {
-		if s.options.ignore {
+		if s.skipped {
+			skipped = append(skipped, s)
+		}
+	}
+	return skipped
+}
+
+// allServices returns the services of the Docker Compose file (or Dockerfile)
+// followed by the services of the FROM instructions of followed Dockerfiles.
+func (p *parser) allServices() []*service {
+	services := append([]*service{}, p.services...)
+	if p.dockerfile != nil {
+		services = append(services, p.dockerfile.services()...)
+	}
+	for _, d := range p.dockerfiles {
+		services = append(services, d.services()...)
+	}
+	return services
+}
+
+// writeServices writes a line per service. Services that share their image
+// (e.g. via 'image: *python') are written in a single line followed by the
+// names of the services.
+func writeServices(w io.Writer, services []*service) {
+	pad := 0
+	for _, s := range services {
+		padLen := len(s.currentImage.String())
+		if pad < padLen {
+			pad = padLen
+		}
+	}
+	shared := sharedServices(services)
+	for _, s := range services {
+		if _, ok := shared[s.alias]; ok {
 			continue
 		}
-		if s.options.warnAll && s.versionHasChanged() ||
-			s.options.warnMajor && s.majorHasChanged() ||
-			s.options.warnMinor && s.minorHasChanged() ||
-			s.options.warnPatch && s.patchHasChanged() {
-			padLen := len(s.currentImage.String())
-			if padWarnings < padLen {
-				padWarnings = padLen
-			}
-			warnings = append(warnings, s)
+		fmt.Fprintf(w, "  %-*s => %s", pad, s.currentImage, s.latestImage)
+		if names, ok := shared[s]; ok {
+			fmt.Fprintf(w, " (%s)", strings.Join(names, ", "))
 		}
+		if name, envFile := s.tagVariable(); envFile != nil {
+			fmt.Fprintf(w, " (%s in %s)", name, envFile.file)
+		} else if s.dockerfile != "" {
+			fmt.Fprintf(w, " (%s in %s)", s.name, s.dockerfile)
+		}
+		fmt.Fprintln(w)
 	}
+}
 
-	if len(changed) > 0 {
-		fmt.Println("Changed versions:")
-		for _, s := range changed {
-			fmt.Printf("  %-*s => %s\n", padChanged, s.currentImage, s.latestImage)
+func writeFailedServices(w io.Writer, services []*service) {
+	pad := 0
+	for _, s := range services {
+		padLen := len(s.currentImage.String())
+		if pad < padLen {
+			pad = padLen
 		}
-		if len(warnings) > 0 {
-			fmt.Println()
-			fmt.Println("Warnings (requires attention):")
-			for _, s := range warnings {
-				fmt.Printf("  %-*s => %s\n", padWarnings, s.currentImage, s.latestImage)
-			}
+	}
+	shared := sharedServices(services)
+	for _, s := range services {
+		if _, ok := shared[s.alias]; ok {
+			continue
+		}
+		fmt.Fprintf(w, "  %-*s => %v", pad, s.currentImage, s.err)
+		if names, ok := shared[s]; ok {
+			fmt.Fprintf(w, " (%s)", strings.Join(names, ", "))
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+// sharedServices returns the names of the services that share the image of a
+// service of the list, including the name of the service itself, by the
+// service that updates the image.
+func sharedServices(services []*service) map[*service][]string {
+	listed := map[*service]bool{}
+	for _, s := range services {
+		listed[s] = true
+	}
+	shared := map[*service][]string{}
+	for _, s := range services {
+		if s.alias == nil || !listed[s.alias] {
+			continue
+		}
+		if _, ok := shared[s.alias]; !ok {
+			shared[s.alias] = []string{s.alias.name}
+		}
+		shared[s.alias] = append(shared[s.alias], s.name)
+	}
+	return shared
+}
+
+func (p *parser) marshal() ([]byte, error) {
+	if p.dockerfile != nil {
+		return p.dockerfile.marshal(p.opts.LineEndings), nil
+	}
+	if p.opts.Reformat || p.source == nil {
+		b, err := p.marshalYaml()
+		return p.format.restore(b, p.opts.LineEndings), err
+	}
+	b, err := p.marshalSource()
+	if errors.Is(err, errNotReplaceable) {
+		// e.g. an image given as block scalar
+		b, err = p.marshalYaml()
+	}
+	return p.format.restore(b, p.opts.LineEndings), err
+}
+
+// marshalSource returns the original content with the values of the changed
+// image nodes replaced.
+func (p *parser) marshalSource() ([]byte, error) {
+	nodes := map[*yaml.Node]string{}
+	for _, s := range p.services {
+		if s.imageNode != nil && s.imageNode.Value != s.imageValue {
+			nodes[s.imageNode] = s.imageValue
 		}
-	} else {
-		fmt.Println("No version changes")
 	}
+	if len(nodes) == 0 {
+		return p.source, nil
+	}
+	return replaceScalars(p.source, nodes)
 }
 
 func (p *parser) marshalYaml() (b []byte, err error) {
@@ -150,6 +578,87 @@ func (p *parser) marshalYaml() (b []byte, err error) {
 	return
 }
 
+// setImage sets the current image of the service. An invalid image reference
+// is recorded as error of the service, so that it is reported like a failed
+// version lookup instead of aborting the whole run.
+func (s *service) setImage(imgStr string) {
+	img, err := newImageFromString(imgStr)
+	if err != nil {
+		s.currentImage = &image{Name: imgStr}
+		s.err = err
+		return
+	}
+	s.currentImage = img
+}
+
+// setImageExpression sets the current image of the service from an image
+// value with variable references, e.g. 'postgres:${PG_VERSION:-14.5}'.
+func (s *service) setImageExpression(value string, env *environment) {
+	if env == nil {
+		env = newEnvironment(nil)
+	}
+	expr, err := newImageExpression(value, env)
+	if err != nil {
+		s.currentImage = &image{Name: value}
+		s.err = err
+		return
+	}
+	s.expr = expr
+	s.setImage(expr.resolved())
+	// the annotations of the variable the tag is taken from apply as well
+	if name, envFile := expr.tagVariable(); envFile != nil {
+		s.options.merge(envFile.options(name))
+	}
+}
+
+// tagVariable returns the variable of an env file the tag of the service is
+// taken from together with the env file, or nil if there is none.
+func (s *service) tagVariable() (string, *envFile) {
+	if s.expr == nil {
+		return "", nil
+	}
+	return s.expr.tagVariable()
+}
+
+func (s *service) updateMode() updateMode {
+	mode := updateMajor
+	if s.options.onlyMinor {
+		mode = updateMinor
+	}
+	if s.options.onlyPatch {
+		mode = updatePatch
+	}
+	return mode
+}
+
+// firedWarnings returns the warning annotations that are triggered by the
+// version change of the service.
+func (s *service) firedWarnings() []string {
+	warnings := []string{}
+	if s.options.warnMajor && s.majorHasChanged() {
+		warnings = append(warnings, "warnMajor")
+	}
+	if s.options.warnMinor && s.minorHasChanged() {
+		warnings = append(warnings, "warnMinor")
+	}
+	if s.options.warnPatch && s.patchHasChanged() {
+		warnings = append(warnings, "warnPatch")
+	}
+	if s.options.warnAll && s.versionHasChanged() {
+		warnings = append(warnings, "warnAll")
+	}
+	return warnings
+}
+
+// hasChanged reports whether the version or the digest of the image has
+// changed.
+func (s *service) hasChanged() bool {
+	if s.currentImage == nil || s.latestImage == nil {
+		return false
+	}
+	return s.versionHasChanged() || !s.currentImage.IsSameDigest(s.latestImage)
+}
+
 func (s *service) versionHasChanged() bool {
 	if s.currentImage == nil || s.latestImage == nil {
 		return false
@@ -180,10 +689,11 @@ func (s *service) patchHasChanged() bool {
 
 func (p *parser) parse(reader io.Reader) error {
 	yamlBytes, err := io.ReadAll(reader)
-	normLineEndings := strings.Replace(string(yamlBytes), "\r\n", "\n", -1)
 	if err != nil {
 		return err
 	}
+	p.source, p.format = splitFormat(yamlBytes)
+	normLineEndings := strings.Replace(string(p.source), "\r\n", "\n", -1)
 	err = yaml.Unmarshal([]byte(normLineEndings), &p.yamlContent)
 	if err != nil {
 		return err
@@ -198,40 +708,196 @@ func (p *parser) parse(reader io.Reader) error {
 		return err
 	}
 
-	servicesNodeContent := servicesNode.Content
-	servicesNodeContentLen := len(servicesNodeContent)
-	for i := 0; i < servicesNodeContentLen; i = i + 2 {
-		if servicesNodeContentLen <= i+1 {
-			return errors.New("could not parese YAML: invalid services node content length")
+	entries, err := mappingEntries(servicesNode)
+	if err != nil {
+		return err
+	}
+	// services that share their image node (via an alias or a merge key) are
+	// updated once by the first service that references it
+	imageOwners := map[*yaml.Node]*service{}
+	for _, entry := range entries {
+		serviceName := entry.key.Value
+		serviceNode := entry.value
+		if p.opts.FollowBuild {
+			err = p.followBuild(serviceName, serviceNode)
+			if err != nil {
+				return err
+			}
 		}
-		imgNodeKey, imgNode, err := getNodeByKey(servicesNodeContent[i+1], "image")
-		if err != nil {
-			return err
+		imgNodeKey, imgNode, err := getNodeByKey(serviceNode, "image")
+		var notFoundErr *keyNotFoundError
+		if errors.As(err, &notFoundErr) {
+			// services that are only built have no image to update
+			p.services = append(p.services, &service{
+				name:    serviceName,
+				options: &serviceOptions{},
+				skipped: true,
+			})
+			continue
 		}
-		img, err := newImageFromString(imgNode.Value)
 		if err != nil {
 			return err
 		}
+		if owner, ok := imageOwners[imgNode]; ok {
+			p.services = append(p.services, &service{
+				name:         serviceName,
+				currentImage: owner.currentImage,
+				expr:         owner.expr,
+				options:      owner.options,
+				alias:        owner,
+				err:          owner.err,
+			})
+			continue
+		}
 		service := &service{
-			name:         servicesNodeContent[i].Value,
-			currentImage: img,
-			imageNode:    imgNode,
-			options:      newServiceOptions(imgNodeKey.HeadComment, imgNode.LineComment),
+			name:       serviceName,
+			imageNode:  imgNode,
+			imageValue: imgNode.Value,
+			options:    newServiceOptions(imgNodeKey.HeadComment, imgNode.LineComment),
+		}
+		if strings.Contains(imgNode.Value, "$") {
+			service.setImageExpression(imgNode.Value, p.env)
+		} else {
+			service.setImage(imgNode.Value)
 		}
+		imageOwners[imgNode] = service
 		p.services = append(p.services, service)
 	}
 	return nil
 }
 
+// followBuild parses the Dockerfile referenced by the build section of the
+// service, if there is one. The build section is either the path of the
+// build context or a mapping with the keys 'context' and 'dockerfile'.
+func (p *parser) followBuild(serviceName string, serviceNode *yaml.Node) error {
+	_, buildNode, err := getNodeByKey(serviceNode, "build")
+	var notFoundErr *keyNotFoundError
+	if errors.As(err, &notFoundErr) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	context := "."
+	dockerfileName := "Dockerfile"
+	if buildNode.Kind == yaml.ScalarNode {
+		context = buildNode.Value
+	} else {
+		if _, contextNode, err := getNodeByKey(buildNode, "context"); err == nil {
+			context = contextNode.Value
+		}
+		if _, dockerfileNode, err := getNodeByKey(buildNode, "dockerfile"); err == nil {
+			dockerfileName = dockerfileNode.Value
+		}
+	}
+	if isRemoteContext(context) {
+		return nil
+	}
+
+	if !filepath.IsAbs(context) {
+		context = filepath.Join(filepath.Dir(p.file), context)
+	}
+	if !filepath.IsAbs(dockerfileName) {
+		dockerfileName = filepath.Join(context, dockerfileName)
+	}
+	dockerfileName = filepath.Clean(dockerfileName)
+	// services with the same build context share their Dockerfile, its
+	// stages are named after the first service
+	for _, d := range p.dockerfiles {
+		if d.file == dockerfileName {
+			return nil
+		}
+	}
+	d, err := newDockerfile(dockerfileName, serviceName+"/")
+	if err != nil {
+		return fmt.Errorf("could not parse Dockerfile of service '%v': %w", serviceName, err)
+	}
+	for _, s := range d.services() {
+		s.dockerfile = d.file
+	}
+	p.dockerfiles = append(p.dockerfiles, d)
+	return nil
+}
+
+// isRemoteContext reports whether the build context is a Git repository or a
+// URL, which can not be followed.
+func isRemoteContext(context string) bool {
+	return strings.Contains(context, "://") || strings.HasPrefix(context, "git@")
+}
+
+// getNodeByKey returns the key and the value node of the key in the mapping.
+// Aliases are resolved and keys that are not part of the mapping itself are
+// looked up in the mappings merged into it (e.g. '<<: *defaults').
 func getNodeByKey(node *yaml.Node, key string) (nodeKey *yaml.Node, nodeVal *yaml.Node, err error) {
-	nodeContent := node.Content
-	for i, n := range nodeContent {
-		if n.Value == key {
-			if len(nodeContent) <= i+1 {
-				return nil, nil, errors.New("could not parese YAML: invalid node content length")
+	entries, err := mappingEntries(node)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, entry := range entries {
+		if entry.key.Value == key {
+			return entry.key, entry.value, nil
+		}
+	}
+	return nil, nil, &keyNotFoundError{key: key}
+}
+
+type mappingEntry struct {
+	key   *yaml.Node
+	value *yaml.Node
+}
+
+// mappingEntries returns the entries of the mapping with resolved aliases.
+// The entries of merged mappings follow the entries of the mapping itself,
+// keys that are already present are skipped. Of a sequence of merged mappings
+// the first mapping that has a key takes precedence.
+func mappingEntries(node *yaml.Node) ([]mappingEntry, error) {
+	node = resolveAlias(node)
+	if node.Kind != yaml.MappingNode {
+		return []mappingEntry{}, nil
+	}
+	content := node.Content
+	if len(content)%2 != 0 {
+		return nil, errors.New("could not parese YAML: invalid node content length")
+	}
+	entries := []mappingEntry{}
+	merged := []*yaml.Node{}
+	seen := map[string]bool{}
+	for i := 0; i < len(content); i += 2 {
+		if isMergeKey(content[i]) {
+			value := resolveAlias(content[i+1])
+			if value.Kind == yaml.SequenceNode {
+				merged = append(merged, value.Content...)
+			} else {
+				merged = append(merged, value)
+			}
+			continue
+		}
+		seen[content[i].Value] = true
+		entries = append(entries, mappingEntry{key: content[i], value: resolveAlias(content[i+1])})
+	}
+	for _, m := range merged {
+		mergedEntries, err := mappingEntries(m)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range mergedEntries {
+			if !seen[entry.key.Value] {
+				seen[entry.key.Value] = true
+				entries = append(entries, entry)
 			}
-			return nodeContent[i], nodeContent[i+1], nil
 		}
 	}
-	return nil, nil, fmt.Errorf("no key '%v' found in YAML", key)
+	return entries, nil
+}
+
+func isMergeKey(node *yaml.Node) bool {
+	return node.Kind == yaml.ScalarNode && node.Value == "<<" && (node.Tag == "!!merge" || node.Tag == "")
+}
+
+func resolveAlias(node *yaml.Node) *yaml.Node {
+	for node.Kind == yaml.AliasNode && node.Alias != nil {
+		node = node.Alias
+	}
+	return node
 }
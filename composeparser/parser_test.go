@@ -1,18 +1,21 @@
 package composeparser
 
 import (
+	"errors"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"reflect"
 	"strings"
+	"sync"
 	"testing"
 
+	"git.larswegmann.de/lars/impose/config"
 	"gopkg.in/yaml.v3"
 )
 
 func TestValidFile(t *testing.T) {
-	_, err := NewParser("fixtures/docker-compose.valid.yml")
+	_, err := NewParser("fixtures/docker-compose.valid.yml", nil)
 
 	if err != nil {
 		t.Errorf("expected no error, got '%v'", err)
@@ -20,7 +23,7 @@ func TestValidFile(t *testing.T) {
 }
 
 func TestNoFileSet(t *testing.T) {
-	_, err := NewParser("")
+	_, err := NewParser("", nil)
 
 	if err == nil {
 		t.Error("expected error when no file given")
@@ -155,6 +158,1283 @@ services:
 	}
 }
 
+func TestImageUpdate_keepFormat(t *testing.T) {
+	const source = "version: \"3\"\r\n" +
+		"services:\r\n" +
+		"  web:\r\n" +
+		"    image: 'nginx:0.1.0' # impose:minor\r\n" +
+		"    command: [\"nginx\", \"-g\", \"daemon off;\"]\r\n" +
+		"\r\n" +
+		"  # the database\r\n" +
+		"  db:\r\n" +
+		"    image: mysql:0.1.0\r\n" +
+		"    environment:\r\n" +
+		"      MYSQL_DATABASE: >-\r\n" +
+		"        app\r\n"
+	parser, err := parserFromStr(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg := &registryMock{
+		getImageVersionsFn: func(imageName string) ([]string, error) {
+			return []string{"0.1.0", "0.1.1", "1.0.0"}, nil
+		},
+	}
+	err = parser.UpdateVersions(reg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := parser.marshal()
+	if err != nil {
+		t.Fatalf("expected no error, got '%v'", err)
+	}
+	expected := strings.Replace(source, "nginx:0.1.0", "nginx:0.1.1", 1)
+	expected = strings.Replace(expected, "mysql:0.1.0", "mysql:1.0.0", 1)
+	if string(b) != expected {
+		t.Errorf("expected '%q', got '%q'", expected, string(b))
+	}
+
+	parser.opts.Reformat = true
+	b, err = parser.marshal()
+	if err != nil {
+		t.Fatalf("expected no error, got '%v'", err)
+	}
+	if string(b) != strings.ReplaceAll(getYamlStr(t, parser), "\n", "\r\n") {
+		t.Errorf("expected the reformatted file with CRLF, got '%q'", string(b))
+	}
+}
+
+func TestImageUpdate_bom(t *testing.T) {
+	const source = utf8BOM + "services:\r\n  web:\r\n    image: nginx:0.1.0\r\n"
+	file := filepath.Join(t.TempDir(), "docker-compose.yml")
+	err := os.WriteFile(file, []byte(source), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		lineEndings string
+		reformat    bool
+		expected    string
+	}{
+		{LineEndingsAuto, false, utf8BOM + "services:\r\n  web:\r\n    image: nginx:1.0.0\r\n"},
+		{LineEndingsAuto, true, utf8BOM + "services:\r\n    web:\r\n        image: nginx:1.0.0\r\n"},
+		{LineEndingsLF, false, utf8BOM + "services:\n  web:\n    image: nginx:1.0.0\n"},
+	}
+	for _, tt := range tests {
+		parser, err := NewParser(file, &Options{LineEndings: tt.lineEndings, Reformat: tt.reformat})
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = parser.UpdateVersions(&registryMock{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := parser.marshal()
+		if err != nil {
+			t.Fatalf("expected no error, got '%v'", err)
+		}
+		if string(b) != tt.expected {
+			t.Errorf("expected '%q', got '%q'", tt.expected, string(b))
+		}
+	}
+}
+
+func TestWriteToStdout_crlf(t *testing.T) {
+	const source = "services:\r\n  web:\r\n    image: nginx:0.1.0\r\n"
+	file := filepath.Join(t.TempDir(), "docker-compose.yml")
+	err := os.WriteFile(file, []byte(source), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parser, err := NewParser(file, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	actual := getStdout(t, func() {
+		err = parser.WriteToStdout()
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got '%v'", err)
+	}
+	if actual != source+"\r\n" {
+		t.Errorf("expected '%q', got '%q'", source+"\r\n", actual)
+	}
+}
+
+func TestNewParser_invalidLineEndings(t *testing.T) {
+	_, err := NewParser("fixtures/docker-compose.valid.yml", &Options{LineEndings: "cr"})
+	if err == nil {
+		t.Error("expected error, got nil")
+	}
+}
+
+func TestImageUpdate_unchangedSource(t *testing.T) {
+	const source = "services:\n  web:\n    image:   nginx:1.0.0\n\n\n"
+	parser, err := parserFromStr(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = parser.UpdateVersions(&registryMock{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := parser.marshal()
+	if err != nil {
+		t.Fatalf("expected no error, got '%v'", err)
+	}
+	if string(b) != source {
+		t.Errorf("expected '%q', got '%q'", source, string(b))
+	}
+}
+
+func TestImageUpdate_blockScalar(t *testing.T) {
+	parser, err := parserFromStr(`services:
+  web:
+    image: >-
+      nginx:0.1.0
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = parser.UpdateVersions(&registryMock{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := parser.marshal()
+	if err != nil {
+		t.Fatalf("expected no error, got '%v'", err)
+	}
+	if !strings.Contains(string(b), "nginx:1.0.0") {
+		t.Errorf("expected the image to be updated, got '%q'", string(b))
+	}
+}
+
+func TestImageUpdate_interpolation(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		".env": "# tags\nREDIS_TAG=0.1.0\nAPI_TAG=\"0.1.0\"\n",
+		"docker-compose.yml": `services:
+  db:
+    image: postgres:${PG_VERSION:-0.1.0}
+  cache:
+    image: redis:${REDIS_TAG}
+  api:
+    image: ${REGISTRY:-registry.example.com}/api:${API_TAG}
+  worker:
+    image: ${REGISTRY:-registry.example.com}/api:${API_TAG}
+  broken:
+    image: nginx:${NGINX_TAG:?is required}
+`,
+	}
+	for name, content := range files {
+		err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	file := filepath.Join(dir, "docker-compose.yml")
+	p, err := NewParser(file, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.env.lookupEnv = func(name string) (string, bool) {
+		return "", false
+	}
+	err = p.UpdateVersions(&registryMock{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = p.WriteToOriginalFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{
+		".env": "# tags\nREDIS_TAG=1.0.0\nAPI_TAG=\"1.0.0\"\n",
+		"docker-compose.yml": `services:
+  db:
+    image: postgres:${PG_VERSION:-1.0.0}
+  cache:
+    image: redis:${REDIS_TAG}
+  api:
+    image: ${REGISTRY:-registry.example.com}/api:${API_TAG}
+  worker:
+    image: ${REGISTRY:-registry.example.com}/api:${API_TAG}
+  broken:
+    image: nginx:${NGINX_TAG:?is required}
+`,
+	}
+	for name, content := range expected {
+		b, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != content {
+			t.Errorf("expected '%q' in '%v', got '%q'", content, name, string(b))
+		}
+	}
+
+	failed := p.failedServices()
+	if len(failed) != 1 || failed[0].name != "broken" {
+		t.Errorf("expected service 'broken' to fail, got '%v'", failed)
+	}
+	changed := p.changedServices()
+	if len(changed) != 4 {
+		t.Errorf("expected 4 changed services, got %v", len(changed))
+	}
+	for _, s := range changed {
+		if s.name == "api" && s.latestImage.String() != "registry.example.com/api:1.0.0" {
+			t.Errorf("expected 'registry.example.com/api:1.0.0', got '%v'", s.latestImage)
+		}
+	}
+}
+
+func TestImageUpdate_envFileOutput(t *testing.T) {
+	tests := []struct {
+		name            string
+		write           func(p *parser, dir string) error
+		expectedEnvFile string
+		expectedStderr  bool
+	}{
+		{
+			"original file",
+			func(p *parser, dir string) error { return p.WriteToOriginalFile() },
+			"REDIS_TAG=1.0.0\n",
+			false,
+		},
+		{
+			"other file",
+			func(p *parser, dir string) error { return p.WriteToFile(filepath.Join(dir, "out.yml")) },
+			"REDIS_TAG=0.1.0\n",
+			true,
+		},
+		{
+			"std out",
+			func(p *parser, dir string) error {
+				getStdout(t, func() {
+					err := p.WriteToStdout()
+					if err != nil {
+						t.Error(err)
+					}
+				})
+				return nil
+			},
+			"REDIS_TAG=0.1.0\n",
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			envFile := filepath.Join(dir, ".env")
+			err := os.WriteFile(envFile, []byte("REDIS_TAG=0.1.0\n"), 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+			file := filepath.Join(dir, "docker-compose.yml")
+			err = os.WriteFile(file, []byte("services:\n  cache:\n    image: redis:${REDIS_TAG}\n"), 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+			p, err := NewParser(file, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			p.env.lookupEnv = func(name string) (string, bool) {
+				return "", false
+			}
+			err = p.UpdateVersions(&registryMock{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			stderr := getStderr(t, func() {
+				err = tt.write(p, dir)
+			})
+			if err != nil {
+				t.Fatalf("expected no error, got '%v'", err)
+			}
+			b, err := os.ReadFile(envFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != tt.expectedEnvFile {
+				t.Errorf("expected '%q', got '%q'", tt.expectedEnvFile, string(b))
+			}
+			if tt.expectedStderr != strings.Contains(stderr, envFile) {
+				t.Errorf("expected a note about '%v' to be %v, got '%v'", envFile, tt.expectedStderr, stderr)
+			}
+		})
+	}
+}
+
+func TestImageUpdate_interpolationVariant(t *testing.T) {
+	p, err := parserFromStr(`services:
+  db:
+    image: postgres:${PG_VERSION:-14.5}-alpine
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = p.UpdateVersions(&registryMock{
+		getImageVersionsFn: func(imageName string) ([]string, error) {
+			return []string{"14.5-alpine", "14.6", "14.6-alpine", "14.6-bullseye"}, nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	const expected = `services:
+  db:
+    image: postgres:${PG_VERSION:-14.6}-alpine
+`
+	b, err := p.marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != expected {
+		t.Errorf("expected '%v', got '%v'", expected, string(b))
+	}
+}
+
+func TestImageUpdate_envFiles(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"tags.env": "# impose:minor\nREDIS_TAG=1.0.0\nAPI_TAG=1.0.0\n",
+		"prod.env": "API_TAG=1.0.0 # impose:patch\n",
+		"docker-compose.yml": `services:
+  cache:
+    image: redis:${REDIS_TAG}
+  api:
+    image: api:${API_TAG} # impose:warnAll
+`,
+	}
+	for name, content := range files {
+		err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	opts := &Options{EnvFiles: []string{filepath.Join(dir, "tags.env"), filepath.Join(dir, "prod.env")}}
+	p, err := NewParser(filepath.Join(dir, "docker-compose.yml"), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.env.lookupEnv = func(name string) (string, bool) {
+		return "", false
+	}
+	reg := &registryMock{
+		getImageVersionsFn: func(imageName string) ([]string, error) {
+			return []string{"1.0.0", "1.0.1", "1.1.0", "2.0.0"}, nil
+		},
+	}
+	err = p.UpdateVersions(reg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := &strings.Builder{}
+	err = p.WriteSummary(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tagsEnv, prodEnv := filepath.Join(dir, "tags.env"), filepath.Join(dir, "prod.env")
+	expected := "Changed versions:\n" +
+		"  redis:1.0.0 => redis:1.1.0 (REDIS_TAG in " + tagsEnv + ")\n" +
+		"  api:1.0.0   => api:1.0.1 (API_TAG in " + prodEnv + ")\n" +
+		"\n" +
+		"Warnings (requires attention):\n" +
+		"  api:1.0.0 => api:1.0.1 (API_TAG in " + prodEnv + ")\n"
+	if b.String() != expected {
+		t.Errorf("expected '%q', got '%q'", expected, b.String())
+	}
+
+	err = p.WriteToOriginalFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedFiles := map[string]string{
+		"tags.env": "# impose:minor\nREDIS_TAG=1.1.0\nAPI_TAG=1.0.0\n",
+		"prod.env": "API_TAG=1.0.1 # impose:patch\n",
+	}
+	for name, content := range expectedFiles {
+		b, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != content {
+			t.Errorf("expected '%q' in '%v', got '%q'", content, name, string(b))
+		}
+	}
+
+	report := p.allServices()[0].report()
+	if report.Variable != "REDIS_TAG" || report.EnvFile != tagsEnv {
+		t.Errorf("expected variable 'REDIS_TAG' in '%v', got '%v' in '%v'", tagsEnv, report.Variable, report.EnvFile)
+	}
+}
+
+func TestNewParser_missingEnvFile(t *testing.T) {
+	_, err := NewParser("fixtures/docker-compose.valid.yml", &Options{EnvFiles: []string{"fixtures/missing.env"}})
+	if err == nil {
+		t.Error("expected error, got nil")
+	}
+}
+
+func TestImageUpdate_anchors(t *testing.T) {
+	const source = `x-defaults: &defaults
+  image: redis:0.1.0 # impose:minor
+  restart: always
+x-base: &base
+  image: nginx:0.1.0
+services:
+  app:
+    image: &py python:0.1.0
+  worker:
+    image: *py
+  cache:
+    <<: *defaults
+  cache-replica:
+    <<: *defaults
+    restart: "no"
+  proxy:
+    <<: [*base, *defaults]
+  custom:
+    <<: *defaults
+    image: redis:0.1.0
+`
+	p, err := parserFromStr(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lookups := map[string]int{}
+	mu := sync.Mutex{}
+	reg := &registryMock{
+		getImageVersionsFn: func(imageName string) ([]string, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			lookups[imageName]++
+			return []string{"0.1.0", "0.2.0", "1.0.0"}, nil
+		},
+	}
+	err = p.UpdateVersions(reg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedLookups := map[string]int{"python": 1, "redis": 2, "nginx": 1}
+	if !reflect.DeepEqual(lookups, expectedLookups) {
+		t.Errorf("expected '%v', got '%v'", expectedLookups, lookups)
+	}
+
+	expectedImages := map[string]string{
+		"app":           "python:1.0.0",
+		"worker":        "python:1.0.0",
+		"cache":         "redis:0.2.0",
+		"cache-replica": "redis:0.2.0",
+		"proxy":         "nginx:1.0.0",
+		"custom":        "redis:1.0.0",
+	}
+	changed := p.changedServices()
+	if len(changed) != len(expectedImages) {
+		t.Errorf("expected %v changed services, got %v", len(expectedImages), len(changed))
+	}
+	for _, s := range changed {
+		if s.latestImage.String() != expectedImages[s.name] {
+			t.Errorf("expected '%v' for '%v', got '%v'", expectedImages[s.name], s.name, s.latestImage)
+		}
+	}
+
+	summary := &strings.Builder{}
+	err = p.WriteSummary(summary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const expectedSummary = `Changed versions:
+  python:0.1.0 => python:1.0.0 (app, worker)
+  redis:0.1.0  => redis:0.2.0 (cache, cache-replica)
+  nginx:0.1.0  => nginx:1.0.0
+  redis:0.1.0  => redis:1.0.0
+`
+	if summary.String() != expectedSummary {
+		t.Errorf("expected '%v', got '%v'", expectedSummary, summary.String())
+	}
+
+	b, err := p.marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := strings.NewReplacer(
+		"image: redis:0.1.0 # impose:minor", "image: redis:0.2.0 # impose:minor",
+		"image: nginx:0.1.0", "image: nginx:1.0.0",
+		"image: &py python:0.1.0", "image: &py python:1.0.0",
+		"    image: redis:0.1.0\n", "    image: redis:1.0.0\n",
+	).Replace(source)
+	if string(b) != expected {
+		t.Errorf("expected '%v', got '%v'", expected, string(b))
+	}
+}
+
+func TestImageUpdate_anchorsFailed(t *testing.T) {
+	p, err := parserFromStr(`services:
+  app:
+    image: &py python:0.1.0
+  worker:
+    image: *py
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = p.UpdateVersions(&registryMock{
+		getImageVersionsFn: func(imageName string) ([]string, error) {
+			return nil, errors.New("not found")
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &strings.Builder{}
+	err = p.WriteSummary(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const expected = `No version changes
+
+Failed version lookups:
+  python:0.1.0 => not found (app, worker)
+`
+	if b.String() != expected {
+		t.Errorf("expected '%v', got '%v'", expected, b.String())
+	}
+}
+
+func TestMappingEntries(t *testing.T) {
+	node := &yaml.Node{}
+	err := yaml.Unmarshal([]byte(`a: &a {x: 1, y: 1}
+b: &b {y: 2, z: 2}
+c:
+  <<: [*a, *b]
+  x: 3
+`), node)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, c, err := getNodeByKey(node.Content[0], "c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := mappingEntries(c)
+	if err != nil {
+		t.Fatalf("expected no error, got '%v'", err)
+	}
+	actual := []string{}
+	for _, entry := range entries {
+		actual = append(actual, entry.key.Value+"="+entry.value.Value)
+	}
+	expected := []string{"x=3", "y=1", "z=2"}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected '%v', got '%v'", expected, actual)
+	}
+}
+
+func TestImageUpdate_digest(t *testing.T) {
+	const file = `services:
+    pinned-by-option:
+        image: alpine:0.1.0
+    pinned-by-annotation:
+        # impose:digest
+        image: nginx:0.1.0
+    already-pinned:
+        image: mysql:1.0.0@sha256:0000000000000000000000000000000000000000000000000000000000000000
+    not-pinned:
+        image: redis:0.1.0
+`
+	tests := []struct {
+		name     string
+		opts     Options
+		expected string
+	}{
+		{
+			"without digest option",
+			Options{},
+			`services:
+    pinned-by-option:
+        image: alpine:1.0.0
+    pinned-by-annotation:
+        # impose:digest
+        image: nginx:1.0.0@sha256:1.0.0
+    already-pinned:
+        image: mysql:1.0.0@sha256:1.0.0
+    not-pinned:
+        image: redis:1.0.0
+`,
+		},
+		{
+			"with digest option",
+			Options{Digest: true},
+			`services:
+    pinned-by-option:
+        image: alpine:1.0.0@sha256:1.0.0
+    pinned-by-annotation:
+        # impose:digest
+        image: nginx:1.0.0@sha256:1.0.0
+    already-pinned:
+        image: mysql:1.0.0@sha256:1.0.0
+    not-pinned:
+        image: redis:1.0.0@sha256:1.0.0
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parser, err := parserFromStr(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			parser.opts = tt.opts
+			err = parser.UpdateVersions(&registryMock{})
+			if err != nil {
+				t.Fatalf("expected no error, got '%v'", err)
+			}
+			actual := getYamlStr(t, parser)
+			if actual != tt.expected {
+				t.Errorf("expected '%q', got '%q'", tt.expected, actual)
+			}
+			// the digest of 'already-pinned' changed although its tag did not
+			if !parser.HasUpdates() {
+				t.Error("expected updates")
+			}
+		})
+	}
+}
+
+func TestImageUpdate_noPrerelease(t *testing.T) {
+	const file = `services:
+    by-option:
+        image: alpine:1.0.0-rc.1
+    by-annotation:
+        image: nginx:1.0.0-rc.1 # impose:noPrerelease
+    with-prereleases:
+        image: mysql:1.0.0-rc.1
+`
+	reg := &registryMock{
+		getImageVersionsFn: func(imageName string) ([]string, error) {
+			return []string{"1.0.0-rc.1", "1.0.0-rc.2", "1.0.1", "1.1.0-beta.1"}, nil
+		},
+	}
+	tests := []struct {
+		name     string
+		opts     Options
+		expected string
+	}{
+		{
+			"without option",
+			Options{},
+			`services:
+    by-option:
+        image: alpine:1.1.0-beta.1
+    by-annotation:
+        image: nginx:1.0.1 # impose:noPrerelease
+    with-prereleases:
+        image: mysql:1.1.0-beta.1
+`,
+		},
+		{
+			"with option",
+			Options{NoPrerelease: true},
+			`services:
+    by-option:
+        image: alpine:1.0.1
+    by-annotation:
+        image: nginx:1.0.1 # impose:noPrerelease
+    with-prereleases:
+        image: mysql:1.0.1
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parser, err := parserFromStr(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			parser.opts = tt.opts
+			err = parser.UpdateVersions(reg)
+			if err != nil {
+				t.Fatalf("expected no error, got '%v'", err)
+			}
+			actual := getYamlStr(t, parser)
+			if actual != tt.expected {
+				t.Errorf("expected '%q', got '%q'", tt.expected, actual)
+			}
+		})
+	}
+}
+
+func TestImageUpdate_constraint(t *testing.T) {
+	parser, err := parserFromStr(`services:
+    postgres:
+        # impose:constraint=~14.2
+        image: postgres:14.2.0
+    alpine:
+        image: alpine:3.16.0 # impose:constraint=">=3.16 <3.19 !=3.18.1"
+    invalid:
+        image: nginx:1.0.0 # impose:constraint=>=a
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg := &registryMock{
+		getImageVersionsFn: func(imageName string) ([]string, error) {
+			if imageName == "postgres" {
+				return []string{"14.2.0", "14.2.5", "14.3.0", "15.1.0"}, nil
+			}
+			return []string{"3.16.0", "3.17.2", "3.18.1", "3.19.0"}, nil
+		},
+	}
+	err = parser.UpdateVersions(reg)
+	if err != nil {
+		t.Fatalf("expected no error, got '%v'", err)
+	}
+	const expected = `services:
+    postgres:
+        # impose:constraint=~14.2
+        image: postgres:14.2.5
+    alpine:
+        image: alpine:3.17.2 # impose:constraint=">=3.16 <3.19 !=3.18.1"
+    invalid:
+        image: nginx:1.0.0 # impose:constraint=>=a
+`
+	actual := getYamlStr(t, parser)
+	if actual != expected {
+		t.Errorf("expected '%q', got '%q'", expected, actual)
+	}
+	if !parser.HasFailures() {
+		t.Error("expected the invalid constraint to be reported as failure")
+	}
+}
+
+func TestImageUpdate_tagScheme(t *testing.T) {
+	parser, err := parserFromStr(`services:
+    minio:
+        image: minio/minio:RELEASE.2022-12-24T10-05-00Z # impose:match=RELEASE\..* impose:sort=date
+    ubuntu:
+        # impose:match=focal-[0-9]{8}
+        # impose:sort=date
+        image: ubuntu:focal-20221130
+    app:
+        # impose:minor
+        # impose:match="(?P<major>\d+)\.(?P<minor>\d+)\.(?P<patch>\d+)-build\d+"
+        image: some/app:2023.04.01-build7
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg := &registryMock{
+		getImageVersionsFn: func(imageName string) ([]string, error) {
+			switch imageName {
+			case "minio/minio":
+				return []string{"latest", "RELEASE.2023-01-12T00-00-00Z", "RELEASE.2022-12-24T10-05-00Z", "RELEASE.2023-01-02T09-40-09Z"}, nil
+			case "ubuntu":
+				return []string{"focal-20221130", "jammy-20230425", "focal-20230412", "focal"}, nil
+			}
+			return []string{"2023.04.01-build7", "2023.05.01-build9", "2023.05.01-build42", "2024.01.01-build1", "nightly"}, nil
+		},
+	}
+	err = parser.UpdateVersions(reg)
+	if err != nil {
+		t.Fatalf("expected no error, got '%v'", err)
+	}
+	if parser.HasFailures() {
+		t.Fatalf("expected no failures")
+	}
+	const expected = `services:
+    minio:
+        image: minio/minio:RELEASE.2023-01-12T00-00-00Z # impose:match=RELEASE\..* impose:sort=date
+    ubuntu:
+        # impose:match=focal-[0-9]{8}
+        # impose:sort=date
+        image: ubuntu:focal-20230412
+    app:
+        # impose:minor
+        # impose:match="(?P<major>\d+)\.(?P<minor>\d+)\.(?P<patch>\d+)-build\d+"
+        image: some/app:2023.05.01-build42
+`
+	actual := getYamlStr(t, parser)
+	if actual != expected {
+		t.Errorf("expected '%q', got '%q'", expected, actual)
+	}
+}
+
+func TestApplyConfig(t *testing.T) {
+	ignore := true
+	cfg := &config.Config{
+		Defaults: config.Policy{Update: "minor", Warn: []string{"major"}},
+		Images: config.ImagePolicies{
+			{Pattern: "library/postgres", Policy: config.Policy{Constraint: "~14"}},
+		},
+		Services: map[string]config.Policy{
+			"legacy": {Ignore: &ignore},
+		},
+	}
+	p, err := parserFromStr(`services:
+    db:
+        image: postgres:14.2.0
+    app:
+        image: some/app:1.0.0 # impose:patch impose:warnMinor
+    override:
+        image: some/app:1.0.0 # impose:major
+    legacy:
+        image: some/legacy:1.0.0
+    built:
+        build: .
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.opts.Config = cfg
+	p.applyConfig()
+	expected := map[string]*serviceOptions{
+		"db": {
+			onlyMinor:  true,
+			warnMajor:  true,
+			constraint: "~14",
+		},
+		"app": {
+			onlyPatch: true,
+			warnMajor: true,
+			warnMinor: true,
+		},
+		"override": {
+			onlyMajor: true,
+			warnMajor: true,
+		},
+		"legacy": {
+			ignore:    true,
+			onlyMinor: true,
+			warnMajor: true,
+		},
+		"built": {},
+	}
+	for _, s := range p.services {
+		if !reflect.DeepEqual(expected[s.name], s.options) {
+			t.Errorf("expected options of '%v' to be %+v, got %+v", s.name, expected[s.name], s.options)
+		}
+	}
+}
+
+func TestImageUpdate_failedDigestLookup(t *testing.T) {
+	parser, err := parserFromStr(`services:
+    my-service:
+        image: alpine:0.1.0@sha256:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg := &registryMock{
+		getImageDigestFn: func(imageName string, tag string) (string, error) {
+			return "", errors.New("registry http error")
+		},
+	}
+	err = parser.UpdateVersions(reg)
+	if err != nil {
+		t.Fatalf("expected no error, got '%v'", err)
+	}
+	if !parser.HasFailures() {
+		t.Error("expected failures")
+	}
+	if parser.HasUpdates() {
+		t.Error("expected no updates")
+	}
+	const expected = `services:
+    my-service:
+        image: alpine:0.1.0@sha256:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa
+`
+	actual := getYamlStr(t, parser)
+	if actual != expected {
+		t.Errorf("expected '%q', got '%q'", expected, actual)
+	}
+}
+
+func TestImageUpdate_invalidReference(t *testing.T) {
+	const file = `services:
+    my-service-1:
+        image: ${REGISTRY}/app:0.1.0
+    my-service-2:
+        image: alpine:0.1.0
+`
+	parser, err := parserFromStr(file)
+	if err != nil {
+		t.Fatalf("expected no error, got '%v'", err)
+	}
+	err = parser.UpdateVersions(&registryMock{})
+	if err != nil {
+		t.Fatalf("expected no error, got '%v'", err)
+	}
+	if !parser.HasFailures() {
+		t.Error("expected failures")
+	}
+	const expected = `services:
+    my-service-1:
+        image: ${REGISTRY}/app:0.1.0
+    my-service-2:
+        image: alpine:1.0.0
+`
+	actual := getYamlStr(t, parser)
+	if actual != expected {
+		t.Errorf("expected '%q', got '%q'", expected, actual)
+	}
+
+	parser, err = parserFromStr(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parser.opts.FailFast = true
+	err = parser.UpdateVersions(&registryMock{})
+	if err == nil {
+		t.Error("expected error, got nil")
+	}
+}
+
+func TestImageUpdate_failedLookup(t *testing.T) {
+	const file = `version: '3'
+services:
+    my-service-1:
+        image: alpine:0.1.0
+    my-service-2:
+        image: custom/image:0.1.0
+`
+	reg := &registryMock{
+		getImageVersionsFn: func(imageName string) ([]string, error) {
+			if imageName == "custom/image" {
+				return nil, errors.New("registry http error for 'custom/image': 404 Not Found")
+			}
+			return []string{"1.0.0"}, nil
+		},
+	}
+
+	parser, err := parserFromStr(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = parser.UpdateVersions(reg)
+	if err != nil {
+		t.Fatalf("expected no error, got '%v'", err)
+	}
+	if !parser.HasFailures() {
+		t.Error("expected failures")
+	}
+
+	actual := getYamlStr(t, parser)
+	const expected = `version: '3'
+services:
+    my-service-1:
+        image: alpine:1.0.0
+    my-service-2:
+        image: custom/image:0.1.0
+`
+	if actual != expected {
+		t.Errorf("expected '%q', got '%q'", expected, actual)
+	}
+
+	b := &strings.Builder{}
+	err = parser.WriteSummary(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const expectedSummary = `Changed versions:
+  alpine:0.1.0 => alpine:1.0.0
+
+Failed version lookups:
+  custom/image:0.1.0 => registry http error for 'custom/image': 404 Not Found
+`
+	if b.String() != expectedSummary {
+		t.Errorf("expected '%q', got '%q'", expectedSummary, b.String())
+	}
+
+	parser, err = parserFromStr(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parser.opts.FailFast = true
+	err = parser.UpdateVersions(reg)
+	if err == nil {
+		t.Error("expected error in fail fast mode")
+	}
+}
+
+func TestBuildOnlyService(t *testing.T) {
+	parser, err := parserFromStr(`version: '3'
+services:
+    my-service-1:
+        build: ./app
+    my-service-2:
+        build:
+            context: ./app
+        image: custom/image:0.1.0
+`)
+	if err != nil {
+		t.Fatalf("expected no error, got '%v'", err)
+	}
+	err = parser.UpdateVersions(&registryMock{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actual := getYamlStr(t, parser)
+	const expected = `version: '3'
+services:
+    my-service-1:
+        build: ./app
+    my-service-2:
+        build:
+            context: ./app
+        image: custom/image:1.0.0
+`
+	if actual != expected {
+		t.Errorf("expected '%q', got '%q'", expected, actual)
+	}
+
+	b := &strings.Builder{}
+	err = parser.WriteSummary(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const expectedSummary = `Changed versions:
+  custom/image:0.1.0 => custom/image:1.0.0
+
+Skipped services (no image):
+  my-service-1
+`
+	if b.String() != expectedSummary {
+		t.Errorf("expected '%q', got '%q'", expectedSummary, b.String())
+	}
+}
+
+func TestFollowBuild(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"docker-compose.yml": `version: '3'
+services:
+    my-service-1:
+        build: ./app
+    my-service-2:
+        build:
+            context: ./app
+            dockerfile: Dockerfile.dev
+        image: custom/image:0.1.0
+    my-service-3:
+        build: https://github.com/some/repo.git
+`,
+		"app/Dockerfile":     "FROM golang:0.1.0 AS builder\nFROM alpine:0.1.0\n",
+		"app/Dockerfile.dev": "FROM golang:1.0.0\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(tmpDir, name)
+		err := os.MkdirAll(filepath.Dir(path), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.WriteFile(path, []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	parser, err := NewParser(filepath.Join(tmpDir, "docker-compose.yml"), &Options{FollowBuild: true})
+	if err != nil {
+		t.Fatalf("expected no error, got '%v'", err)
+	}
+	actualServices := []string{}
+	for _, s := range parser.allServices() {
+		actualServices = append(actualServices, s.name)
+	}
+	expectedServices := []string{
+		"my-service-1",
+		"my-service-2",
+		"my-service-3",
+		"my-service-1/builder",
+		"my-service-1/stage-1",
+		"my-service-2/stage-0",
+	}
+	if !reflect.DeepEqual(expectedServices, actualServices) {
+		t.Errorf("expected services %v, got %v", expectedServices, actualServices)
+	}
+
+	err = parser.UpdateVersions(&registryMock{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = parser.WriteToOriginalFile()
+	if err != nil {
+		t.Fatalf("expected no error, got '%v'", err)
+	}
+	b, err := os.ReadFile(filepath.Join(tmpDir, "app/Dockerfile"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	const expected = "FROM golang:1.0.0 AS builder\nFROM alpine:1.0.0\n"
+	if string(b) != expected {
+		t.Errorf("expected '%q', got '%q'", expected, string(b))
+	}
+}
+
+func TestFollowBuild_sharedDockerfile(t *testing.T) {
+	tmpDir := t.TempDir()
+	file := filepath.Join(tmpDir, "docker-compose.yml")
+	err := os.WriteFile(file, []byte(`services:
+    web:
+        build: .
+    worker:
+        build:
+            context: ./
+            dockerfile: ./Dockerfile
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(filepath.Join(tmpDir, "Dockerfile"), []byte("FROM alpine:0.1.0\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parser, err := NewParser(file, &Options{FollowBuild: true})
+	if err != nil {
+		t.Fatalf("expected no error, got '%v'", err)
+	}
+	if len(parser.dockerfiles) != 1 {
+		t.Errorf("expected 1 Dockerfile, got %v", len(parser.dockerfiles))
+	}
+	lookups := 0
+	err = parser.UpdateVersions(&registryMock{
+		getImageVersionsFn: func(imageName string) ([]string, error) {
+			lookups++
+			return []string{"0.1.0", "1.0.0"}, nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lookups != 1 {
+		t.Errorf("expected 1 lookup, got %v", lookups)
+	}
+
+	b := &strings.Builder{}
+	err = parser.WriteSummary(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `Changed versions:
+  alpine:0.1.0 => alpine:1.0.0 (web/stage-0 in ` + filepath.Join(tmpDir, "Dockerfile") + `)
+
+Skipped services (no image):
+  web
+  worker
+`
+	if b.String() != expected {
+		t.Errorf("expected '%q', got '%q'", expected, b.String())
+	}
+	r := parser.report()
+	if r.Services[2].Dockerfile != filepath.Join(tmpDir, "Dockerfile") {
+		t.Errorf("expected the Dockerfile in the report, got '%v'", r.Services[2].Dockerfile)
+	}
+}
+
+func TestFollowBuild_output(t *testing.T) {
+	tests := []struct {
+		name               string
+		write              func(p *parser, dir string) error
+		expectedDockerfile string
+		expectedStderr     bool
+	}{
+		{
+			"original file",
+			func(p *parser, dir string) error { return p.WriteToOriginalFile() },
+			"FROM alpine:1.0.0\n",
+			false,
+		},
+		{
+			"other file",
+			func(p *parser, dir string) error { return p.WriteToFile(filepath.Join(dir, "out.yml")) },
+			"FROM alpine:0.1.0\n",
+			true,
+		},
+		{
+			"std out",
+			func(p *parser, dir string) error {
+				getStdout(t, func() {
+					err := p.WriteToStdout()
+					if err != nil {
+						t.Error(err)
+					}
+				})
+				return nil
+			},
+			"FROM alpine:0.1.0\n",
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			dockerfile := filepath.Join(dir, "Dockerfile")
+			err := os.WriteFile(dockerfile, []byte("FROM alpine:0.1.0\n"), 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+			file := filepath.Join(dir, "docker-compose.yml")
+			err = os.WriteFile(file, []byte("services:\n  web:\n    build: .\n"), 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+			p, err := NewParser(file, &Options{FollowBuild: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = p.UpdateVersions(&registryMock{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			stderr := getStderr(t, func() {
+				err = tt.write(p, dir)
+			})
+			if err != nil {
+				t.Fatalf("expected no error, got '%v'", err)
+			}
+			b, err := os.ReadFile(dockerfile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != tt.expectedDockerfile {
+				t.Errorf("expected '%q', got '%q'", tt.expectedDockerfile, string(b))
+			}
+			if tt.expectedStderr != strings.Contains(stderr, dockerfile) {
+				t.Errorf("expected a note about '%v' to be %v, got '%v'", dockerfile, tt.expectedStderr, stderr)
+			}
+		})
+	}
+}
+
+func TestFollowBuild_missingDockerfile(t *testing.T) {
+	tmpDir := t.TempDir()
+	file := filepath.Join(tmpDir, "docker-compose.yml")
+	err := os.WriteFile(file, []byte(`version: '3'
+services:
+    my-service:
+        build: ./app
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = NewParser(file, &Options{FollowBuild: true})
+	if err == nil {
+		t.Error("expected error")
+	}
+}
+
 func TestOptions(t *testing.T) {
 	var tests = []struct {
 		name     string
@@ -369,6 +1649,109 @@ services:
 	}
 }
 
+func TestHasUpdatesAndWarnings(t *testing.T) {
+	tests := []struct {
+		name             string
+		file             string
+		expectedUpdates  bool
+		expectedWarnings bool
+	}{
+		{
+			"up to date",
+			`version: '3'
+services:
+    my-service:
+        image: alpine:1.0.0 # impose:warnAll
+`,
+			false,
+			false,
+		},
+		{
+			"update without warning",
+			`version: '3'
+services:
+    my-service:
+        image: alpine:0.1.0
+`,
+			true,
+			false,
+		},
+		{
+			"update with warning",
+			`version: '3'
+services:
+    my-service:
+        image: alpine:0.1.0 # impose:warnMajor
+`,
+			true,
+			true,
+		},
+		{
+			"ignored update",
+			`version: '3'
+services:
+    my-service:
+        image: alpine:0.1.0 # impose:ignore impose:warnAll
+`,
+			false,
+			false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := parserFromStr(tt.file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = p.UpdateVersions(&registryMock{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.expectedUpdates != p.HasUpdates() {
+				t.Errorf("expected HasUpdates to be '%v', got '%v'", tt.expectedUpdates, p.HasUpdates())
+			}
+			if tt.expectedWarnings != p.HasWarnings() {
+				t.Errorf("expected HasWarnings to be '%v', got '%v'", tt.expectedWarnings, p.HasWarnings())
+			}
+		})
+	}
+}
+
+func TestWriteSummary(t *testing.T) {
+	p, err := parserFromStr(`version: '3'
+services:
+    my-service-1:
+        image: alpine:0.1.0 # impose:warnMajor
+    my-service-2:
+        image: custom/image:0.1.0
+    my-service-3:
+        image: mysql:1.0.0
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = p.UpdateVersions(&registryMock{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := &strings.Builder{}
+	err = p.WriteSummary(b)
+	if err != nil {
+		t.Fatalf("expected no error, got '%v'", err)
+	}
+	const expected = `Changed versions:
+  alpine:0.1.0       => alpine:1.0.0
+  custom/image:0.1.0 => custom/image:1.0.0
+
+Warnings (requires attention):
+  alpine:0.1.0 => alpine:1.0.0
+`
+	if b.String() != expected {
+		t.Errorf("expected '%q', got '%q'", expected, b.String())
+	}
+}
+
 func TestWriteToFile(t *testing.T) {
 	tmpDir := t.TempDir()
 	filePath := filepath.Join(tmpDir, "docker-compose.yml")
@@ -420,14 +1803,22 @@ func getYamlStr(t *testing.T, p *parser) string {
 }
 
 func getStdout(t *testing.T, runFunc func()) string {
-	origStdout := os.Stdout
-	defer func() { os.Stdout = origStdout }()
+	return captureOutput(t, &os.Stdout, runFunc)
+}
+
+func getStderr(t *testing.T, runFunc func()) string {
+	return captureOutput(t, &os.Stderr, runFunc)
+}
+
+func captureOutput(t *testing.T, out **os.File, runFunc func()) string {
+	origOut := *out
+	defer func() { *out = origOut }()
 
 	r, w, err := os.Pipe()
 	if err != nil {
 		t.Fatal(err)
 	}
-	os.Stdout = w
+	*out = w
 	runFunc()
 	err = w.Close()
 	if err != nil {
@@ -0,0 +1,137 @@
+package composeparser
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// prereleaseKeywords are the identifiers a pre-release suffix starts with,
+// e.g. 'rc.1' in '1.2.3-rc.1'. Suffixes starting with any other identifier
+// are variants of the image, e.g. 'alpine' in '1.2.3-alpine'.
+var prereleaseKeywords = map[string]bool{
+	"alpha":     true,
+	"beta":      true,
+	"rc":        true,
+	"pre":       true,
+	"preview":   true,
+	"dev":       true,
+	"snapshot":  true,
+	"canary":    true,
+	"nightly":   true,
+	"next":      true,
+	"milestone": true,
+}
+
+// versionSuffix returns the suffix of the version string without build
+// metadata, e.g. 'rc.1' for '1.2.3-rc.1+build.5'.
+func versionSuffix(version string) string {
+	version, _, _ = strings.Cut(version, "+")
+	_, suffix, _ := strings.Cut(version, "-")
+	return suffix
+}
+
+// isPrerelease reports whether the version string is a pre-release.
+func isPrerelease(version string) bool {
+	prerelease, _ := splitSuffix(versionSuffix(version))
+	return prerelease != ""
+}
+
+// splitSuffix splits the suffix of a version into the pre-release and the
+// variant part, e.g. 'rc.1-alpine' into 'rc.1' and 'alpine'. Parts that are
+// separated by '-' and follow a pre-release keyword belong to the
+// pre-release as long as they start with a digit or a keyword themselves.
+func splitSuffix(suffix string) (string, string) {
+	parts := strings.Split(suffix, "-")
+	if !isPrereleaseKeyword(parts[0]) {
+		return "", suffix
+	}
+	n := 1
+	for n < len(parts) && (startsWithDigit(parts[n]) || isPrereleaseKeyword(parts[n])) {
+		n++
+	}
+	return strings.Join(parts[:n], "-"), strings.Join(parts[n:], "-")
+}
+
+func isPrereleaseKeyword(part string) bool {
+	letters := strings.IndexFunc(part, func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	if letters < 0 {
+		letters = len(part)
+	}
+	return prereleaseKeywords[strings.ToLower(part[:letters])]
+}
+
+func startsWithDigit(str string) bool {
+	return str != "" && str[0] >= '0' && str[0] <= '9'
+}
+
+// comparePrerelease compares two pre-releases following the precedence rules
+// of Semantic Versioning 2.0 and returns -1, 0 or +1. A release (empty
+// pre-release) has a higher precedence than any pre-release. Identifiers are
+// compared one by one, numeric identifiers numerically and all others
+// lexically, numeric identifiers have a lower precedence than alphanumeric
+// ones. Letters and digits are split into separate identifiers, so that
+// 'rc10' is compared like 'rc.10'.
+func comparePrerelease(a string, b string) int {
+	if a == b {
+		return 0
+	}
+	if a == "" {
+		return 1
+	}
+	if b == "" {
+		return -1
+	}
+	aIDs, bIDs := prereleaseIdentifiers(a), prereleaseIdentifiers(b)
+	for idx := 0; idx < len(aIDs) && idx < len(bIDs); idx++ {
+		if c := compareIdentifier(aIDs[idx], bIDs[idx]); c != 0 {
+			return c
+		}
+	}
+	switch {
+	case len(aIDs) < len(bIDs):
+		return -1
+	case len(aIDs) > len(bIDs):
+		return 1
+	}
+	return 0
+}
+
+func prereleaseIdentifiers(prerelease string) []string {
+	ids := []string{}
+	for _, field := range strings.FieldsFunc(prerelease, func(r rune) bool {
+		return r == '.' || r == '-'
+	}) {
+		start := 0
+		for idx := 1; idx < len(field); idx++ {
+			if startsWithDigit(field[idx:]) != startsWithDigit(field[idx-1:]) {
+				ids = append(ids, field[start:idx])
+				start = idx
+			}
+		}
+		ids = append(ids, field[start:])
+	}
+	return ids
+}
+
+func compareIdentifier(a string, b string) int {
+	aNum, aErr := strconv.Atoi(a)
+	bNum, bErr := strconv.Atoi(b)
+	switch {
+	case aErr == nil && bErr == nil:
+		if aNum < bNum {
+			return -1
+		}
+		if aNum > bNum {
+			return 1
+		}
+		return 0
+	case aErr == nil:
+		return -1
+	case bErr == nil:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
@@ -0,0 +1,56 @@
+package composeparser
+
+import "testing"
+
+func TestSplitSuffix(t *testing.T) {
+	tests := []struct {
+		suffix             string
+		expectedPrerelease string
+		expectedVariant    string
+	}{
+		{"", "", ""},
+		{"alpine", "", "alpine"},
+		{"rc.1", "rc.1", ""},
+		{"RC1", "RC1", ""},
+		{"beta-2", "beta-2", ""},
+		{"rc.1-alpine", "rc.1", "alpine"},
+		{"alpine-rc.1", "", "alpine-rc.1"},
+		{"preview", "preview", ""},
+		{"previous", "", "previous"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.suffix, func(t *testing.T) {
+			prerelease, variant := splitSuffix(tt.suffix)
+			if tt.expectedPrerelease != prerelease || tt.expectedVariant != variant {
+				t.Errorf("expected '%v' and '%v', got '%v' and '%v'", tt.expectedPrerelease, tt.expectedVariant, prerelease, variant)
+			}
+		})
+	}
+}
+
+func TestComparePrerelease(t *testing.T) {
+	tests := []struct {
+		a        string
+		b        string
+		expected int
+	}{
+		{"", "", 0},
+		{"rc.1", "", -1},
+		{"", "rc.1", 1},
+		{"rc.2", "rc.10", -1},
+		{"rc2", "rc10", -1},
+		{"alpha", "beta", -1},
+		{"beta.11", "beta.2", 1},
+		{"alpha", "alpha.1", -1},
+		{"alpha.1", "alpha.beta", -1},
+		{"rc.1", "rc.1", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.a+"_"+tt.b, func(t *testing.T) {
+			actual := comparePrerelease(tt.a, tt.b)
+			if tt.expected != actual {
+				t.Errorf("expected '%v', got '%v'", tt.expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package composeparser
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// maxNameLength is the maximum length of the name of an image reference.
+const maxNameLength = 255
+
+// The regular expressions follow the grammar of Docker image references:
+//
+//	reference        := name [ ":" tag ] [ "@" digest ]
+//	name             := [ domain "/" ] path-component [ "/" path-component ]*
+//	domain           := host [ ":" port-number ]
+//	host             := domain-name | "[" IPv6address "]"
+//	domain-name      := domain-component [ "." domain-component ]*
+//	domain-component := /([a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9-]*[a-zA-Z0-9])/
+//	port-number      := /[0-9]+/
+//	path-component   := alpha-numeric [ separator alpha-numeric ]*
+//	alpha-numeric    := /[a-z0-9]+/
+//	separator        := /[_.]|__|[-]*/
+//	tag              := /[\w][\w.-]{0,127}/
+//	digest           := algorithm ":" hex
+//	algorithm        := /[A-Za-z][A-Za-z0-9]*([-_+.][A-Za-z][A-Za-z0-9]*)*/
+//	hex              := /[0-9a-fA-F]{32,}/
+const (
+	rePathComponent   = `[a-z0-9]+(?:(?:[._]|__|[-]+)[a-z0-9]+)*`
+	reDomainComponent = `(?:[a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9-]*[a-zA-Z0-9])`
+	reHost            = `(?:` + reDomainComponent + `(?:\.` + reDomainComponent + `)*|\[[a-fA-F0-9:]+\])`
+	reTag             = `[\w][\w.-]{0,127}`
+	reDigest          = `[A-Za-z][A-Za-z0-9]*(?:[-_+.][A-Za-z][A-Za-z0-9]*)*:[0-9a-fA-F]{32,}`
+)
+
+// reReference matches a complete image reference. The groups are the host,
+// the port, the path, the tag and the digest.
+var reReference = regexp.MustCompile(`^(?:(` + reHost + `)(?::([0-9]+))?/)?` +
+	`(` + rePathComponent + `(?:/` + rePathComponent + `)*)` +
+	`(?::(` + reTag + `))?` +
+	`(?:@(` + reDigest + `))?$`)
+
+// reference is an image reference split into its parts, e.g.
+// 'registry.example.com:5000/team/api:2.0@sha256:...'.
+type reference struct {
+	domain string
+	port   string
+	path   string
+	tag    string
+	digest string
+}
+
+// parseReference splits the image reference into its parts. The first
+// component of the name is only treated as domain if it contains a '.' or a
+// ':', is 'localhost' or contains upper case letters, otherwise it is part of
+// the path (e.g. 'library/alpine').
+func parseReference(str string) (*reference, error) {
+	matches := reReference.FindStringSubmatch(str)
+	if matches == nil {
+		if str != "" && strings.ToLower(str) != str && reReference.MatchString(strings.ToLower(str)) {
+			return nil, fmt.Errorf("invalid image reference '%v': repository name must be lowercase", str)
+		}
+		return nil, fmt.Errorf("invalid image reference '%v'", str)
+	}
+	ref := &reference{
+		domain: matches[1],
+		port:   matches[2],
+		path:   matches[3],
+		tag:    matches[4],
+		digest: matches[5],
+	}
+	if ref.domain != "" && ref.port == "" && !isDomain(ref.domain) {
+		ref.path = ref.domain + "/" + ref.path
+		ref.domain = ""
+	}
+	if len(ref.name()) > maxNameLength {
+		return nil, fmt.Errorf("invalid image reference '%v': repository name must not be longer than %v characters", str, maxNameLength)
+	}
+	return ref, nil
+}
+
+func isDomain(host string) bool {
+	return strings.ContainsAny(host, ".:") || host == "localhost" || strings.ToLower(host) != host
+}
+
+// name returns the name of the referenced image including the domain and
+// port, but without tag and digest.
+func (r *reference) name() string {
+	name := r.path
+	if r.port != "" {
+		name = r.domain + ":" + r.port + "/" + name
+	} else if r.domain != "" {
+		name = r.domain + "/" + name
+	}
+	return name
+}
+
+func (r *reference) String() string {
+	str := r.name()
+	if r.tag != "" {
+		str = str + ":" + r.tag
+	}
+	if r.digest != "" {
+		str = str + "@" + r.digest
+	}
+	return str
+}
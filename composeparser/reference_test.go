@@ -0,0 +1,120 @@
+package composeparser
+
+import (
+	"strings"
+	"testing"
+)
+
+const testDigest = "sha256:4ff3ca91275773af45cb4b0834e12b7eb47d1c18f770a0b151381cd227f4c253"
+
+func TestParseReference(t *testing.T) {
+	tests := []struct {
+		name     string
+		str      string
+		expected reference
+	}{
+		{
+			"official image",
+			"alpine",
+			reference{path: "alpine"},
+		},
+		{
+			"image with tag",
+			"alpine:3.16.3",
+			reference{path: "alpine", tag: "3.16.3"},
+		},
+		{
+			"image with user",
+			"library/alpine:3.16.3",
+			reference{path: "library/alpine", tag: "3.16.3"},
+		},
+		{
+			"image with digest",
+			"alpine@" + testDigest,
+			reference{path: "alpine", digest: testDigest},
+		},
+		{
+			"image with tag and digest",
+			"alpine:3.16.3@" + testDigest,
+			reference{path: "alpine", tag: "3.16.3", digest: testDigest},
+		},
+		{
+			"domain",
+			"ghcr.io/org/app:1.2.3",
+			reference{domain: "ghcr.io", path: "org/app", tag: "1.2.3"},
+		},
+		{
+			"domain with port",
+			"registry.example.com:5000/team/api:2.0",
+			reference{domain: "registry.example.com", port: "5000", path: "team/api", tag: "2.0"},
+		},
+		{
+			"localhost with port",
+			"localhost:5000/app:1.2.3",
+			reference{domain: "localhost", port: "5000", path: "app", tag: "1.2.3"},
+		},
+		{
+			"localhost without port",
+			"localhost/app",
+			reference{domain: "localhost", path: "app"},
+		},
+		{
+			"host without dot with port",
+			"myregistry:5000/app",
+			reference{domain: "myregistry", port: "5000", path: "app"},
+		},
+		{
+			"IPv6 host",
+			"[::1]:5000/app:1.0",
+			reference{domain: "[::1]", port: "5000", path: "app", tag: "1.0"},
+		},
+		{
+			"port, tag and digest",
+			"localhost:5000/app:1.2.3@" + testDigest,
+			reference{domain: "localhost", port: "5000", path: "app", tag: "1.2.3", digest: testDigest},
+		},
+		{
+			"separators in path",
+			"some-org/my_app.server__x:1.0-alpine",
+			reference{path: "some-org/my_app.server__x", tag: "1.0-alpine"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ref, err := parseReference(tt.str)
+			if err != nil {
+				t.Fatalf("expected no error, got '%v'", err)
+			}
+			if tt.expected != *ref {
+				t.Errorf("expected '%+v', got '%+v'", tt.expected, *ref)
+			}
+			if tt.str != ref.String() {
+				t.Errorf("expected '%v', got '%v'", tt.str, ref.String())
+			}
+		})
+	}
+}
+
+func TestParseReference_invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		str  string
+	}{
+		{"empty", ""},
+		{"upper case path", "Alpine:3.16.3"},
+		{"empty tag", "alpine:"},
+		{"invalid tag", "alpine:.3"},
+		{"short digest", "alpine@sha256:abc"},
+		{"trailing slash", "org/app/"},
+		{"variable", "${REGISTRY}/app:1.0"},
+		{"too long", strings.Repeat("a", 256)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseReference(tt.str)
+			if err == nil {
+				t.Error("expected error, got nil")
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package composeparser
+
+import (
+	"encoding/json"
+	"io"
+)
+
+type report struct {
+	File     string           `json:"file"`
+	Services []*serviceReport `json:"services"`
+}
+
+type serviceReport struct {
+	Service      string   `json:"service"`
+	CurrentImage string   `json:"currentImage,omitempty"`
+	LatestImage  string   `json:"latestImage,omitempty"`
+	UpdateMode   string   `json:"updateMode"`
+	Ignored      bool     `json:"ignored"`
+	Skipped      bool     `json:"skipped"`
+	Changed      bool     `json:"changed"`
+	Warnings     []string `json:"warnings"`
+	Error        string   `json:"error,omitempty"`
+	// Variable and EnvFile name the variable the tag is taken from
+	Variable string `json:"variable,omitempty"`
+	EnvFile  string `json:"envFile,omitempty"`
+	// Dockerfile is the followed Dockerfile of a build stage
+	Dockerfile string `json:"dockerfile,omitempty"`
+}
+
+var reportUpdateModes = map[updateMode]string{
+	updateMajor: "major",
+	updateMinor: "minor",
+	updatePatch: "patch",
+}
+
+// WriteJSONReport writes a machine readable report of the update results of
+// all services. The report is an array with a single file report, so that it
+// has the same shape as the report of several files.
+func (p *parser) WriteJSONReport(w io.Writer) error {
+	return writeJSONReports(w, []*report{p.report()})
+}
+
+func writeJSONReports(w io.Writer, reports []*report) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(reports)
+}
+
+func (p *parser) report() *report {
+	r := &report{
+		File:     p.file,
+		Services: []*serviceReport{},
+	}
+	for _, s := range p.allServices() {
+		r.Services = append(r.Services, s.report())
+	}
+	return r
+}
+
+func (s *service) report() *serviceReport {
+	r := &serviceReport{
+		Service:    s.name,
+		UpdateMode: reportUpdateModes[s.updateMode()],
+		Ignored:    s.options.ignore,
+		Skipped:    s.skipped,
+		Warnings:   []string{},
+	}
+	if s.currentImage != nil {
+		r.CurrentImage = s.currentImage.String()
+	}
+	if s.latestImage != nil {
+		r.LatestImage = s.latestImage.String()
+	}
+	if !s.options.ignore && !s.skipped {
+		r.Changed = s.hasChanged()
+		r.Warnings = s.firedWarnings()
+	}
+	if s.err != nil {
+		r.Error = s.err.Error()
+	}
+	if name, envFile := s.tagVariable(); envFile != nil {
+		r.Variable, r.EnvFile = name, envFile.file
+	}
+	r.Dockerfile = s.dockerfile
+	return r
+}
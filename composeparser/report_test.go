@@ -0,0 +1,95 @@
+package composeparser
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestWriteJSONReport(t *testing.T) {
+	p, err := parserFromStr(`version: '3'
+services:
+    my-service-1:
+        image: alpine:0.1.0 # impose:warnMajor
+    my-service-2:
+        image: custom/image:0.1.0 # impose:ignore
+    my-service-3:
+        image: mysql:1.0.0 # impose:minor
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.file = "docker-compose.yml"
+	err = p.UpdateVersions(&registryMock{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := &bytes.Buffer{}
+	err = p.WriteJSONReport(b)
+	if err != nil {
+		t.Fatalf("expected no error, got '%v'", err)
+	}
+	const expected = `[
+  {
+    "file": "docker-compose.yml",
+    "services": [
+      {
+        "service": "my-service-1",
+        "currentImage": "alpine:0.1.0",
+        "latestImage": "alpine:1.0.0",
+        "updateMode": "major",
+        "ignored": false,
+        "skipped": false,
+        "changed": true,
+        "warnings": [
+          "warnMajor"
+        ]
+      },
+      {
+        "service": "my-service-2",
+        "currentImage": "custom/image:0.1.0",
+        "updateMode": "major",
+        "ignored": true,
+        "skipped": false,
+        "changed": false,
+        "warnings": []
+      },
+      {
+        "service": "my-service-3",
+        "currentImage": "mysql:1.0.0",
+        "latestImage": "mysql:1.0.0",
+        "updateMode": "minor",
+        "ignored": false,
+        "skipped": false,
+        "changed": false,
+        "warnings": []
+      }
+    ]
+  }
+]
+`
+	if b.String() != expected {
+		t.Errorf("expected '%v', got '%v'", expected, b.String())
+	}
+}
+
+func TestServiceReport_error(t *testing.T) {
+	img, err := newImageFromString("alpine:0.1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &service{
+		name:         "my-service",
+		currentImage: img,
+		options:      &serviceOptions{},
+		err:          errors.New("some error"),
+	}
+	r := s.report()
+	if r.Error != "some error" {
+		t.Errorf("expected error 'some error', got '%v'", r.Error)
+	}
+	if r.LatestImage != "" {
+		t.Errorf("expected no latest image, got '%v'", r.LatestImage)
+	}
+}
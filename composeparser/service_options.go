@@ -2,33 +2,132 @@ package composeparser
 
 import (
 	"strings"
+	"unicode"
+
+	"git.larswegmann.de/lars/impose/config"
 )
 
 type serviceOptions struct {
-	ignore    bool
-	onlyMinor bool
-	onlyPatch bool
-	warnMajor bool
-	warnMinor bool
-	warnPatch bool
-	warnAll   bool
+	ignore       bool
+	onlyMajor    bool
+	onlyMinor    bool
+	onlyPatch    bool
+	warnMajor    bool
+	warnMinor    bool
+	warnPatch    bool
+	warnAll      bool
+	digest       bool
+	noPrerelease bool
+	constraint   string
+	match        string
+	sort         string
 }
 
 func newServiceOptions(headComment string, lineComment string) *serviceOptions {
-	comment := headComment + lineComment
+	comment := headComment + "\n" + lineComment
 
 	return &serviceOptions{
-		ignore:    containsOption(comment, "ignore"),
-		onlyMinor: containsOption(comment, "minor"),
-		onlyPatch: containsOption(comment, "patch"),
-		warnMajor: containsOption(comment, "warnMajor"),
-		warnMinor: containsOption(comment, "warnMinor"),
-		warnPatch: containsOption(comment, "warnPatch"),
-		warnAll:   containsOption(comment, "warnAll"),
+		ignore:       containsOption(comment, "ignore"),
+		onlyMajor:    containsOption(comment, "major"),
+		onlyMinor:    containsOption(comment, "minor"),
+		onlyPatch:    containsOption(comment, "patch"),
+		warnMajor:    containsOption(comment, "warnMajor"),
+		warnMinor:    containsOption(comment, "warnMinor"),
+		warnPatch:    containsOption(comment, "warnPatch"),
+		warnAll:      containsOption(comment, "warnAll"),
+		digest:       containsOption(comment, "digest"),
+		noPrerelease: containsOption(comment, "noPrerelease"),
+		constraint:   getOptionValue(comment, "constraint"),
+		match:        getOptionValue(comment, "match"),
+		sort:         getOptionValue(comment, "sort"),
 	}
 }
 
+// merge adds the options of further annotations, e.g. of the variable in an
+// env file the tag is taken from. The update mode is only used if there is no
+// update mode annotation yet, flags are combined and values are only used if
+// they are not given yet.
+func (o *serviceOptions) merge(other *serviceOptions) {
+	if !o.onlyMajor && !o.onlyMinor && !o.onlyPatch {
+		o.onlyMajor, o.onlyMinor, o.onlyPatch = other.onlyMajor, other.onlyMinor, other.onlyPatch
+	}
+	o.ignore = o.ignore || other.ignore
+	o.warnMajor = o.warnMajor || other.warnMajor
+	o.warnMinor = o.warnMinor || other.warnMinor
+	o.warnPatch = o.warnPatch || other.warnPatch
+	o.warnAll = o.warnAll || other.warnAll
+	o.digest = o.digest || other.digest
+	o.noPrerelease = o.noPrerelease || other.noPrerelease
+	if o.constraint == "" {
+		o.constraint = other.constraint
+	}
+	if o.match == "" {
+		o.match = other.match
+	}
+	if o.sort == "" {
+		o.sort = other.sort
+	}
+}
+
+// applyPolicy merges a policy of the config file into the options. The inline
+// annotations take precedence: the update mode of the policy is only used if
+// there is no update mode annotation, while warnings and flags are combined
+// and values are only used if the annotation is not given.
+func (o *serviceOptions) applyPolicy(p config.Policy) {
+	if !o.onlyMajor && !o.onlyMinor && !o.onlyPatch {
+		o.onlyMinor = p.Update == "minor"
+		o.onlyPatch = p.Update == "patch"
+	}
+	for _, w := range p.Warn {
+		switch w {
+		case "major":
+			o.warnMajor = true
+		case "minor":
+			o.warnMinor = true
+		case "patch":
+			o.warnPatch = true
+		case "all":
+			o.warnAll = true
+		}
+	}
+	o.ignore = o.ignore || isTrue(p.Ignore)
+	o.digest = o.digest || isTrue(p.Digest)
+	o.noPrerelease = o.noPrerelease || isTrue(p.NoPrerelease)
+	if o.constraint == "" {
+		o.constraint = p.Constraint
+	}
+	if o.match == "" {
+		o.match = p.Match
+	}
+	if o.sort == "" {
+		o.sort = p.Sort
+	}
+}
+
+func isTrue(b *bool) bool {
+	return b != nil && *b
+}
+
 func containsOption(comment string, option string) bool {
 	optionStr := "impose:" + option
 	return strings.Contains(comment, optionStr)
 }
+
+// getOptionValue returns the value of an option given as
+// 'impose:option=value'. The value ends at the next whitespace or '#' unless
+// it is quoted, e.g. 'impose:constraint=">=1.4 <2"'.
+func getOptionValue(comment string, option string) string {
+	optionStr := "impose:" + option + "="
+	_, value, found := strings.Cut(comment, optionStr)
+	if !found {
+		return ""
+	}
+	if value != "" && (value[0] == '"' || value[0] == '\'') {
+		quoted, _, _ := strings.Cut(value[1:], value[:1])
+		return quoted
+	}
+	if idx := strings.IndexFunc(value, func(r rune) bool { return unicode.IsSpace(r) || r == '#' }); idx >= 0 {
+		value = value[:idx]
+	}
+	return value
+}
@@ -41,3 +41,121 @@ func Test_containsOption(t *testing.T) {
 		})
 	}
 }
+
+func Test_getOptionValue(t *testing.T) {
+	tests := []struct {
+		name    string
+		comment string
+		want    string
+	}{
+		{
+			"no option",
+			"#impose:minor",
+			"",
+		},
+		{
+			"unquoted value",
+			"#impose:constraint=~14.2 other comment text",
+			"~14.2",
+		},
+		{
+			"double quoted value",
+			`#impose:constraint=">=3.16 <3.19 !=3.17.1" other comment text`,
+			">=3.16 <3.19 !=3.17.1",
+		},
+		{
+			"single quoted value",
+			"#impose:constraint='>=1.4 <2'",
+			">=1.4 <2",
+		},
+		{
+			"multiline comment",
+			"#line1\n#impose:constraint=<2\n#line3",
+			"<2",
+		},
+		{
+			"value followed by comment",
+			"#impose:constraint=~14.2# other comment",
+			"~14.2",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := getOptionValue(tt.comment, "constraint"); got != tt.want {
+				t.Errorf("getOptionValue() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewServiceOptions(t *testing.T) {
+	tests := []struct {
+		name        string
+		headComment string
+		lineComment string
+		expected    *serviceOptions
+	}{
+		{
+			"head and line comment",
+			"# impose:constraint=~14.2",
+			"# impose:warnMajor",
+			&serviceOptions{constraint: "~14.2", warnMajor: true},
+		},
+		{
+			"values in both comments",
+			"# impose:match=^\\d+$",
+			"# impose:sort=semver",
+			&serviceOptions{match: "^\\d+$", sort: "semver"},
+		},
+		{
+			"head comment only",
+			"# impose:minor impose:constraint=<2",
+			"",
+			&serviceOptions{onlyMinor: true, constraint: "<2"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual := newServiceOptions(tt.headComment, tt.lineComment)
+			if *actual != *tt.expected {
+				t.Errorf("expected '%+v', got '%+v'", *tt.expected, *actual)
+			}
+		})
+	}
+}
+
+func TestServiceOptions_merge(t *testing.T) {
+	tests := []struct {
+		name     string
+		options  *serviceOptions
+		other    *serviceOptions
+		expected *serviceOptions
+	}{
+		{
+			"update mode of other",
+			&serviceOptions{warnMajor: true},
+			&serviceOptions{onlyMinor: true, warnMinor: true, constraint: "<2"},
+			&serviceOptions{onlyMinor: true, warnMajor: true, warnMinor: true, constraint: "<2"},
+		},
+		{
+			"own update mode",
+			&serviceOptions{onlyPatch: true, constraint: "~1.2"},
+			&serviceOptions{onlyMinor: true, ignore: true, constraint: "<2"},
+			&serviceOptions{onlyPatch: true, ignore: true, constraint: "~1.2"},
+		},
+		{
+			"major overrides other",
+			&serviceOptions{onlyMajor: true},
+			&serviceOptions{onlyMinor: true},
+			&serviceOptions{onlyMajor: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.options.merge(tt.other)
+			if *tt.options != *tt.expected {
+				t.Errorf("expected '%+v', got '%+v'", *tt.expected, *tt.options)
+			}
+		})
+	}
+}
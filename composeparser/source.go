@@ -0,0 +1,111 @@
+package composeparser
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// errNotReplaceable is returned if a scalar can not be replaced in the source,
+// e.g. because it is a block scalar or spans several lines.
+var errNotReplaceable = errors.New("scalar can not be replaced in the source")
+
+// replacement replaces the bytes from start to end of the source.
+type replacement struct {
+	start int
+	end   int
+	value string
+}
+
+// replaceScalars returns the source with the values of the given scalar
+// nodes replaced. Only the bytes of the scalars are changed, so the
+// formatting, quoting and line endings of the rest of the source are kept.
+// The original values of the nodes are needed to locate them in the source.
+func replaceScalars(source []byte, nodes map[*yaml.Node]string) ([]byte, error) {
+	lineStarts := lineOffsets(source)
+	replacements := []replacement{}
+	for node, original := range nodes {
+		r, err := scalarReplacement(source, lineStarts, node, original)
+		if err != nil {
+			return nil, err
+		}
+		replacements = append(replacements, r)
+	}
+	sort.Slice(replacements, func(i, j int) bool {
+		return replacements[i].start < replacements[j].start
+	})
+
+	b := &strings.Builder{}
+	pos := 0
+	for _, r := range replacements {
+		if r.start < pos {
+			return nil, fmt.Errorf("overlapping scalars at offset %v", r.start)
+		}
+		b.Write(source[pos:r.start])
+		b.WriteString(r.value)
+		pos = r.end
+	}
+	b.Write(source[pos:])
+	return []byte(b.String()), nil
+}
+
+// lineOffsets returns the offsets of the beginnings of all lines.
+func lineOffsets(source []byte) []int {
+	offsets := []int{0}
+	for idx, c := range source {
+		if c == '\n' {
+			offsets = append(offsets, idx+1)
+		}
+	}
+	return offsets
+}
+
+func scalarReplacement(source []byte, lineStarts []int, node *yaml.Node, original string) (replacement, error) {
+	if node.Kind != yaml.ScalarNode || node.Line < 1 || node.Line > len(lineStarts) {
+		return replacement{}, errNotReplaceable
+	}
+	start := lineStarts[node.Line-1]
+	// the column counts characters, not bytes
+	for col := 1; col < node.Column && start < len(source); col++ {
+		_, size := utf8.DecodeRune(source[start:])
+		start += size
+	}
+	start = skipProperties(source, start)
+
+	raw, value := "", node.Value
+	switch node.Style {
+	case 0:
+		raw = original
+	case yaml.SingleQuotedStyle:
+		raw = "'" + strings.ReplaceAll(original, "'", "''") + "'"
+		value = "'" + strings.ReplaceAll(value, "'", "''") + "'"
+	case yaml.DoubleQuotedStyle:
+		raw = `"` + original + `"`
+		value = `"` + value + `"`
+	default:
+		return replacement{}, errNotReplaceable
+	}
+	end := start + len(raw)
+	if end > len(source) || string(source[start:end]) != raw {
+		return replacement{}, errNotReplaceable
+	}
+	return replacement{start: start, end: end, value: value}, nil
+}
+
+// skipProperties skips the anchor and the tag of a node (e.g. '&img !!str'),
+// which are part of the node's position.
+func skipProperties(source []byte, pos int) int {
+	for pos < len(source) && (source[pos] == '&' || source[pos] == '!') {
+		for pos < len(source) && source[pos] != ' ' && source[pos] != '\t' && source[pos] != '\n' && source[pos] != '\r' {
+			pos++
+		}
+		for pos < len(source) && (source[pos] == ' ' || source[pos] == '\t') {
+			pos++
+		}
+	}
+	return pos
+}
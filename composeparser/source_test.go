@@ -0,0 +1,101 @@
+package composeparser
+
+import (
+	"errors"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestReplaceScalars(t *testing.T) {
+	tests := []struct {
+		name     string
+		source   string
+		expected string
+	}{
+		{
+			"plain",
+			"services:\n  app:\n    image: alpine:3.15.5\n",
+			"services:\n  app:\n    image: alpine:3.16.3\n",
+		},
+		{
+			"inline comment",
+			"services:\n  app:\n    image: alpine:3.15.5   # impose:minor\n",
+			"services:\n  app:\n    image: alpine:3.16.3   # impose:minor\n",
+		},
+		{
+			"single quoted",
+			"services:\n  app:\n    image: 'alpine:3.15.5'\n",
+			"services:\n  app:\n    image: 'alpine:3.16.3'\n",
+		},
+		{
+			"double quoted",
+			"services:\n  app:\n    image: \"alpine:3.15.5\"\n",
+			"services:\n  app:\n    image: \"alpine:3.16.3\"\n",
+		},
+		{
+			"CRLF",
+			"services:\r\n  app:\r\n    image: alpine:3.15.5\r\n    restart: always\r\n",
+			"services:\r\n  app:\r\n    image: alpine:3.16.3\r\n    restart: always\r\n",
+		},
+		{
+			"flow mapping",
+			"services: {app: {labels: {name: \"äöü\"}, image: alpine:3.15.5}}\n",
+			"services: {app: {labels: {name: \"äöü\"}, image: alpine:3.16.3}}\n",
+		},
+		{
+			"anchor",
+			"x-image: &img alpine:3.15.5\nservices:\n  app:\n    image: *img\n",
+			"x-image: &img alpine:3.16.3\nservices:\n  app:\n    image: *img\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := findScalar(t, tt.source, "alpine:3.15.5")
+			node.Value = "alpine:3.16.3"
+			b, err := replaceScalars([]byte(tt.source), map[*yaml.Node]string{node: "alpine:3.15.5"})
+			if err != nil {
+				t.Fatalf("expected no error, got '%v'", err)
+			}
+			if string(b) != tt.expected {
+				t.Errorf("expected '%q', got '%q'", tt.expected, string(b))
+			}
+		})
+	}
+}
+
+func TestReplaceScalars_notReplaceable(t *testing.T) {
+	source := "services:\n  app:\n    image: >-\n      alpine:3.15.5\n"
+	node := findScalar(t, source, "alpine:3.15.5")
+	node.Value = "alpine:3.16.3"
+	_, err := replaceScalars([]byte(source), map[*yaml.Node]string{node: "alpine:3.15.5"})
+	if !errors.Is(err, errNotReplaceable) {
+		t.Errorf("expected '%v', got '%v'", errNotReplaceable, err)
+	}
+}
+
+func findScalar(t *testing.T, source string, value string) *yaml.Node {
+	t.Helper()
+	root := &yaml.Node{}
+	err := yaml.Unmarshal([]byte(source), root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var find func(n *yaml.Node) *yaml.Node
+	find = func(n *yaml.Node) *yaml.Node {
+		if n.Kind == yaml.ScalarNode && n.Value == value {
+			return n
+		}
+		for _, c := range n.Content {
+			if found := find(c); found != nil {
+				return found
+			}
+		}
+		return nil
+	}
+	node := find(root)
+	if node == nil {
+		t.Fatalf("no scalar '%v' found", value)
+	}
+	return node
+}
@@ -0,0 +1,153 @@
+package composeparser
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+type tagSort int
+
+const (
+	sortSemver tagSort = iota
+	sortLexical
+	sortDate
+)
+
+var tagSorts = map[string]tagSort{
+	"semver":  sortSemver,
+	"lexical": sortLexical,
+	"date":    sortDate,
+}
+
+var reNumber = regexp.MustCompile(`[0-9]+`)
+
+// tagScheme is a custom tag scheme given by the 'impose:match' and
+// 'impose:sort' annotations. Only tags matching the regular expression are
+// update candidates. The named groups 'major', 'minor' and 'patch' of the
+// expression set the version parts of the tag.
+type tagScheme struct {
+	re   *regexp.Regexp
+	sort tagSort
+}
+
+func newTagScheme(match string, sort string) (*tagScheme, error) {
+	s := &tagScheme{}
+	if match != "" {
+		// the expression has to match the whole tag
+		re, err := regexp.Compile("^(?:" + match + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid match expression '%v': %v", match, err)
+		}
+		s.re = re
+	}
+	if sort != "" {
+		var ok bool
+		s.sort, ok = tagSorts[sort]
+		if !ok {
+			return nil, fmt.Errorf("invalid sort order '%v', must be 'semver', 'lexical' or 'date'", sort)
+		}
+	}
+	return s, nil
+}
+
+func (s *tagScheme) matches(tag string) bool {
+	return s.re == nil || s.re.MatchString(tag)
+}
+
+// hasVersionGroups reports whether the expression has named groups for the
+// version parts.
+func (s *tagScheme) hasVersionGroups() bool {
+	if s.re == nil {
+		return false
+	}
+	for _, group := range []string{"major", "minor", "patch"} {
+		if s.re.SubexpIndex(group) >= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// apply sets the version parts of the image from the named groups of the
+// expression. Parts without a group are set to 0.
+func (s *tagScheme) apply(img *image) {
+	if !s.hasVersionGroups() {
+		return
+	}
+	matches := s.re.FindStringSubmatch(img.VersionStr)
+	if matches == nil {
+		return
+	}
+	group := func(name string) int {
+		idx := s.re.SubexpIndex(name)
+		if idx < 0 {
+			return 0
+		}
+		n, _ := strconv.Atoi(matches[idx])
+		return n
+	}
+	img.Major, img.Minor, img.Patch = group("major"), group("minor"), group("patch")
+	img.Suffix = ""
+}
+
+// less reports whether the tag of a is sorted before the tag of b.
+func (s *tagScheme) less(a *image, b *image) bool {
+	switch s.sort {
+	case sortLexical:
+		return a.VersionStr < b.VersionStr
+	case sortDate:
+		if c := compareNumbers(a.VersionStr, b.VersionStr); c != 0 {
+			return c < 0
+		}
+		return a.VersionStr < b.VersionStr
+	}
+	if a.Less(b) || b.Less(a) {
+		return a.Less(b)
+	}
+	// e.g. the build number in '2023.05.01-build42'
+	if c := compareNumbers(a.VersionStr, b.VersionStr); c != 0 {
+		return c < 0
+	}
+	return a.VersionStr < b.VersionStr
+}
+
+// compareNumbers compares all numbers of the strings one by one, e.g.
+// '2023-01-12T00-00-00Z' and '20230101' are compared chronologically.
+func compareNumbers(a string, b string) int {
+	aNums, bNums := reNumber.FindAllString(a, -1), reNumber.FindAllString(b, -1)
+	for idx := 0; idx < len(aNums) && idx < len(bNums); idx++ {
+		if c := compareNumeric(aNums[idx], bNums[idx]); c != 0 {
+			return c
+		}
+	}
+	switch {
+	case len(aNums) < len(bNums):
+		return -1
+	case len(aNums) > len(bNums):
+		return 1
+	}
+	return 0
+}
+
+// compareNumeric compares two strings of digits by their numeric value
+// without the risk of an overflow.
+func compareNumeric(a string, b string) int {
+	for len(a) > 1 && a[0] == '0' {
+		a = a[1:]
+	}
+	for len(b) > 1 && b[0] == '0' {
+		b = b[1:]
+	}
+	switch {
+	case len(a) < len(b):
+		return -1
+	case len(a) > len(b):
+		return 1
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
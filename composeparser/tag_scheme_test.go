@@ -0,0 +1,139 @@
+package composeparser
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestNewTagScheme_invalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		match string
+		sort  string
+	}{
+		{"invalid expression", "(", ""},
+		{"invalid sort order", "", "random"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newTagScheme(tt.match, tt.sort)
+			if err == nil {
+				t.Error("expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestTagSchemeMatches(t *testing.T) {
+	s, err := newTagScheme(`focal-\d{8}`, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		tag      string
+		expected bool
+	}{
+		{"focal-20230101", true},
+		{"jammy-20230101", false},
+		{"focal-20230101-1", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.tag, func(t *testing.T) {
+			if actual := s.matches(tt.tag); tt.expected != actual {
+				t.Errorf("expected '%v', got '%v'", tt.expected, actual)
+			}
+		})
+	}
+}
+
+func TestTagSchemeApply(t *testing.T) {
+	s, err := newTagScheme(`(?P<major>\d+)\.(?P<minor>\d+)\.(?P<patch>\d+)-build\d+`, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	img := &image{}
+	img.setVersionFromStr("2023.05.01-build42")
+	s.apply(img)
+	expected := &versionParts{Major: 2023, Minor: 5, Patch: 1, VersionStr: "2023.05.01-build42"}
+	assertVersionParts(t, img, expected)
+}
+
+func TestTagSchemeLess(t *testing.T) {
+	tests := []struct {
+		name     string
+		match    string
+		sort     string
+		tags     []string
+		expected []string
+	}{
+		{
+			"semver with build number",
+			`(?P<major>\d+)\.(?P<minor>\d+)\.(?P<patch>\d+)-build\d+`,
+			"semver",
+			[]string{"2023.05.01-build42", "2023.05.01-build9", "2022.12.24-build100"},
+			[]string{"2022.12.24-build100", "2023.05.01-build9", "2023.05.01-build42"},
+		},
+		{
+			"lexical",
+			"",
+			"lexical",
+			[]string{"b", "c", "a"},
+			[]string{"a", "b", "c"},
+		},
+		{
+			"date",
+			`RELEASE\..*`,
+			"date",
+			[]string{"RELEASE.2023-01-12T00-00-00Z", "RELEASE.2022-12-24T10-05-00Z", "RELEASE.2023-01-02T09-40-09Z"},
+			[]string{"RELEASE.2022-12-24T10-05-00Z", "RELEASE.2023-01-02T09-40-09Z", "RELEASE.2023-01-12T00-00-00Z"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := newTagScheme(tt.match, tt.sort)
+			if err != nil {
+				t.Fatal(err)
+			}
+			imgs := []*image{}
+			for _, tag := range tt.tags {
+				img := &image{}
+				img.setVersionFromStr(tag)
+				s.apply(img)
+				imgs = append(imgs, img)
+			}
+			sort.Slice(imgs, func(i, j int) bool {
+				return s.less(imgs[i], imgs[j])
+			})
+			actual := []string{}
+			for _, img := range imgs {
+				actual = append(actual, img.VersionStr)
+			}
+			if !reflect.DeepEqual(tt.expected, actual) {
+				t.Errorf("expected %v, got %v", tt.expected, actual)
+			}
+		})
+	}
+}
+
+func TestCompareNumbers(t *testing.T) {
+	tests := []struct {
+		a        string
+		b        string
+		expected int
+	}{
+		{"20230101", "20221231", 1},
+		{"focal-20230101", "focal-20230101", 0},
+		{"2023-01-12", "2023-01-02", 1},
+		{"2023-1-2", "2023-01-02", 0},
+		{"1", "1.1", -1},
+		{"99999999999999999999", "100000000000000000000", -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.a+"_"+tt.b, func(t *testing.T) {
+			if actual := compareNumbers(tt.a, tt.b); tt.expected != actual {
+				t.Errorf("expected '%v', got '%v'", tt.expected, actual)
+			}
+		})
+	}
+}
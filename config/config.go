@@ -0,0 +1,257 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FileNames are the names of the config file that are discovered next to the
+// Docker Compose file.
+var FileNames = []string{".impose.yml", ".impose.yaml"}
+
+var updateModes = map[string]bool{
+	"major": true,
+	"minor": true,
+	"patch": true,
+}
+
+var warnings = map[string]bool{
+	"major": true,
+	"minor": true,
+	"patch": true,
+	"all":   true,
+}
+
+// Config is the content of a config file. The policies are merged in the
+// following order, later ones take precedence: defaults, image patterns (in
+// the order of the file), services and the ignore list. The inline
+// annotations of the Docker Compose file take precedence over all of them.
+type Config struct {
+	Defaults Policy            `yaml:"defaults"`
+	Images   ImagePolicies     `yaml:"images"`
+	Services map[string]Policy `yaml:"services"`
+	// Ignore lists the services and image patterns that are ignored for
+	// updates.
+	Ignore   []string `yaml:"ignore"`
+	Registry Registry `yaml:"registry"`
+}
+
+// Policy defines how the images are updated. Unset fields do not override
+// the fields of a policy with lower precedence.
+type Policy struct {
+	// Update is the update mode: major, minor or patch.
+	Update string `yaml:"update"`
+	// Warn lists the version changes that trigger a warning: major, minor,
+	// patch or all.
+	Warn         []string `yaml:"warn"`
+	Ignore       *bool    `yaml:"ignore"`
+	Constraint   string   `yaml:"constraint"`
+	NoPrerelease *bool    `yaml:"noPrerelease"`
+	Digest       *bool    `yaml:"digest"`
+	Match        string   `yaml:"match"`
+	Sort         string   `yaml:"sort"`
+}
+
+// ImagePolicy is a policy for all images whose name matches the pattern.
+type ImagePolicy struct {
+	Pattern string
+	Policy  Policy
+}
+
+// ImagePolicies keeps the order of the image patterns in the file.
+type ImagePolicies []ImagePolicy
+
+// Registry holds the settings for the version lookup. Environment variables
+// (e.g. '${REGISTRY_PASSWORD}') are expanded.
+type Registry struct {
+	URL          string `yaml:"url"`
+	User         string `yaml:"user"`
+	Password     string `yaml:"password"`
+	DockerConfig string `yaml:"dockerConfig"`
+	MaxPages     *int   `yaml:"maxPages"`
+}
+
+// Find returns the path of the config file in the given directory or an
+// empty string if there is none.
+func Find(dir string) string {
+	for _, name := range FileNames {
+		file := filepath.Join(dir, name)
+		if _, err := os.Stat(file); err == nil {
+			return file
+		}
+	}
+	return ""
+}
+
+// Load reads and validates the config file.
+func Load(file string) (*Config, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := parse(b)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config file '%v': %w", file, err)
+	}
+	return cfg, nil
+}
+
+func parse(b []byte) (*Config, error) {
+	cfg := &Config{}
+	// an empty file is a valid config
+	err := decodeStrict(b, cfg)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	err = cfg.validate()
+	if err != nil {
+		return nil, err
+	}
+	cfg.Registry.expandEnv()
+	return cfg, nil
+}
+
+func (c *Config) validate() error {
+	err := c.Defaults.validate()
+	if err != nil {
+		return fmt.Errorf("defaults: %w", err)
+	}
+	for _, img := range c.Images {
+		if _, err := path.Match(img.Pattern, ""); err != nil {
+			return fmt.Errorf("images: invalid pattern '%v'", img.Pattern)
+		}
+		err = img.Policy.validate()
+		if err != nil {
+			return fmt.Errorf("images '%v': %w", img.Pattern, err)
+		}
+	}
+	for name, p := range c.Services {
+		err = p.validate()
+		if err != nil {
+			return fmt.Errorf("services '%v': %w", name, err)
+		}
+	}
+	for _, pattern := range c.Ignore {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("ignore: invalid pattern '%v'", pattern)
+		}
+	}
+	return nil
+}
+
+func (p *Policy) validate() error {
+	if p.Update != "" && !updateModes[p.Update] {
+		return fmt.Errorf("invalid update mode '%v', must be 'major', 'minor' or 'patch'", p.Update)
+	}
+	for _, w := range p.Warn {
+		if !warnings[w] {
+			return fmt.Errorf("invalid warning '%v', must be 'major', 'minor', 'patch' or 'all'", w)
+		}
+	}
+	return nil
+}
+
+// Policy returns the merged policy for the service with the given image
+// names. The image names are matched against the image patterns, e.g. the
+// name as written in the file and its normalized name.
+func (c *Config) Policy(service string, imageNames ...string) Policy {
+	p := c.Defaults
+	for _, img := range c.Images {
+		if matchesAny(img.Pattern, imageNames) {
+			p = p.merge(img.Policy)
+		}
+	}
+	if servicePolicy, ok := c.Services[service]; ok {
+		p = p.merge(servicePolicy)
+	}
+	for _, pattern := range c.Ignore {
+		if matchesAny(pattern, append([]string{service}, imageNames...)) {
+			ignore := true
+			p.Ignore = &ignore
+		}
+	}
+	return p
+}
+
+func matchesAny(pattern string, names []string) bool {
+	for _, name := range names {
+		if ok, _ := path.Match(pattern, name); ok && name != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// merge returns the policy with all fields that are set in the other policy
+// replaced.
+func (p Policy) merge(other Policy) Policy {
+	if other.Update != "" {
+		p.Update = other.Update
+	}
+	if other.Warn != nil {
+		p.Warn = other.Warn
+	}
+	if other.Ignore != nil {
+		p.Ignore = other.Ignore
+	}
+	if other.Constraint != "" {
+		p.Constraint = other.Constraint
+	}
+	if other.NoPrerelease != nil {
+		p.NoPrerelease = other.NoPrerelease
+	}
+	if other.Digest != nil {
+		p.Digest = other.Digest
+	}
+	if other.Match != "" {
+		p.Match = other.Match
+	}
+	if other.Sort != "" {
+		p.Sort = other.Sort
+	}
+	return p
+}
+
+func (p *ImagePolicies) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %v: images must be a mapping of image patterns to policies", node.Line)
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		img := ImagePolicy{
+			Pattern: node.Content[i].Value,
+		}
+		// decode the policy with the same strictness as the whole file
+		b, err := yaml.Marshal(node.Content[i+1])
+		if err != nil {
+			return err
+		}
+		err = decodeStrict(b, &img.Policy)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+		*p = append(*p, img)
+	}
+	return nil
+}
+
+// decodeStrict decodes the YAML document and fails on unknown fields, so that
+// typos in the config file are not silently ignored.
+func decodeStrict(b []byte, out interface{}) error {
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	dec.KnownFields(true)
+	return dec.Decode(out)
+}
+
+func (r *Registry) expandEnv() {
+	r.URL = os.ExpandEnv(r.URL)
+	r.User = os.ExpandEnv(r.User)
+	r.Password = os.ExpandEnv(r.Password)
+	r.DockerConfig = os.ExpandEnv(r.DockerConfig)
+}
@@ -0,0 +1,172 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+func writeConfig(t *testing.T, dir string, name string, content string) string {
+	file := filepath.Join(dir, name)
+	err := os.WriteFile(file, []byte(content), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestFind(t *testing.T) {
+	dir := t.TempDir()
+	if file := Find(dir); file != "" {
+		t.Errorf("expected no config file, got '%v'", file)
+	}
+	expected := writeConfig(t, dir, ".impose.yaml", "")
+	if file := Find(dir); file != expected {
+		t.Errorf("expected '%v', got '%v'", expected, file)
+	}
+	expected = writeConfig(t, dir, ".impose.yml", "")
+	if file := Find(dir); file != expected {
+		t.Errorf("expected '%v', got '%v'", expected, file)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	t.Setenv("IMPOSE_TEST_PASSWORD", "secret")
+	file := writeConfig(t, t.TempDir(), ".impose.yml", `defaults:
+  update: minor
+  warn: [major]
+images:
+  "postgres":
+    constraint: "~14"
+  "ghcr.io/org/*":
+    ignore: true
+services:
+  db:
+    update: patch
+ignore:
+  - legacy
+registry:
+  url: https://registry.example.com
+  user: user
+  password: ${IMPOSE_TEST_PASSWORD}
+  maxPages: 10
+`)
+	cfg, err := Load(file)
+	if err != nil {
+		t.Fatalf("expected no error, got '%v'", err)
+	}
+	expectedImages := ImagePolicies{
+		{Pattern: "postgres", Policy: Policy{Constraint: "~14"}},
+		{Pattern: "ghcr.io/org/*", Policy: Policy{Ignore: boolPtr(true)}},
+	}
+	if !reflect.DeepEqual(expectedImages, cfg.Images) {
+		t.Errorf("expected %+v, got %+v", expectedImages, cfg.Images)
+	}
+	if cfg.Registry.Password != "secret" {
+		t.Errorf("expected expanded password 'secret', got '%v'", cfg.Registry.Password)
+	}
+	if cfg.Registry.MaxPages == nil || *cfg.Registry.MaxPages != 10 {
+		t.Errorf("expected max pages 10, got '%v'", cfg.Registry.MaxPages)
+	}
+}
+
+func TestLoad_empty(t *testing.T) {
+	_, err := Load(writeConfig(t, t.TempDir(), ".impose.yml", ""))
+	if err != nil {
+		t.Errorf("expected no error, got '%v'", err)
+	}
+}
+
+func TestLoad_invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"invalid YAML", "defaults: ["},
+		{"unknown field", "defaults:\n  updte: minor\n"},
+		{"unknown field in image policy", "images:\n  alpine:\n    updte: minor\n"},
+		{"invalid update mode", "defaults:\n  update: all\n"},
+		{"invalid warning", "services:\n  db:\n    warn: [everything]\n"},
+		{"invalid pattern", "images:\n  \"[\":\n    ignore: true\n"},
+		{"images not a mapping", "images:\n  - alpine\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(writeConfig(t, t.TempDir(), ".impose.yml", tt.content))
+			if err == nil {
+				t.Error("expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestLoad_missingFile(t *testing.T) {
+	_, err := Load(filepath.Join(t.TempDir(), ".impose.yml"))
+	if err == nil {
+		t.Error("expected error, got nil")
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	cfg := &Config{
+		Defaults: Policy{Update: "minor", Warn: []string{"major"}, Digest: boolPtr(true)},
+		Images: ImagePolicies{
+			{Pattern: "library/*", Policy: Policy{Update: "patch", Constraint: "<2"}},
+			{Pattern: "postgres", Policy: Policy{Constraint: "~14"}},
+		},
+		Services: map[string]Policy{
+			"db": {Update: "major", Warn: []string{}, Digest: boolPtr(false)},
+		},
+		Ignore: []string{"legacy", "ghcr.io/org/*"},
+	}
+	tests := []struct {
+		name       string
+		service    string
+		imageNames []string
+		expected   Policy
+	}{
+		{
+			"defaults",
+			"app",
+			[]string{"some/app"},
+			Policy{Update: "minor", Warn: []string{"major"}, Digest: boolPtr(true)},
+		},
+		{
+			"image patterns in order",
+			"cache",
+			[]string{"postgres", "library/postgres"},
+			Policy{Update: "patch", Warn: []string{"major"}, Digest: boolPtr(true), Constraint: "~14"},
+		},
+		{
+			"service overrides image patterns",
+			"db",
+			[]string{"postgres", "library/postgres"},
+			Policy{Update: "major", Warn: []string{}, Digest: boolPtr(false), Constraint: "~14"},
+		},
+		{
+			"ignored service",
+			"legacy",
+			[]string{"some/app"},
+			Policy{Update: "minor", Warn: []string{"major"}, Digest: boolPtr(true), Ignore: boolPtr(true)},
+		},
+		{
+			"ignored image",
+			"app",
+			[]string{"ghcr.io/org/app"},
+			Policy{Update: "minor", Warn: []string{"major"}, Digest: boolPtr(true), Ignore: boolPtr(true)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual := cfg.Policy(tt.service, tt.imageNames...)
+			if !reflect.DeepEqual(tt.expected, actual) {
+				t.Errorf("expected %+v, got %+v", tt.expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,175 @@
+package registry
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultTokenLifetime is used if the token server does not return an
+// expiration (see https://docs.docker.com/registry/spec/auth/token/).
+const defaultTokenLifetime = 60 * time.Second
+
+// tokenExpiryLeeway lets tokens expire a bit earlier than announced, so that
+// they don't expire while a request is in flight.
+const tokenExpiryLeeway = 5 * time.Second
+
+type authChallenge struct {
+	scheme string
+	params map[string]string
+}
+
+type token struct {
+	value     string
+	expiresAt time.Time
+}
+
+type tokenResponse struct {
+	Token       string    `json:"token"`
+	AccessToken string    `json:"access_token"`
+	ExpiresIn   int       `json:"expires_in"`
+	IssuedAt    time.Time `json:"issued_at"`
+}
+
+type tokenCache struct {
+	mu     sync.Mutex
+	tokens map[string]*token
+}
+
+func newTokenCache() *tokenCache {
+	return &tokenCache{
+		tokens: map[string]*token{},
+	}
+}
+
+func (c *tokenCache) get(scope string, now time.Time) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t, ok := c.tokens[scope]
+	if !ok {
+		return ""
+	}
+	if !now.Before(t.expiresAt) {
+		delete(c.tokens, scope)
+		return ""
+	}
+	return t.value
+}
+
+func (c *tokenCache) set(scope string, t *token) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.tokens[scope] = t
+}
+
+// fetchToken requests a new bearer token from the realm given by the challenge
+// and stores it in the token cache for the given scope.
+func (r *Registry) fetchToken(challenge *authChallenge, scope string) (string, error) {
+	realm := challenge.params["realm"]
+	if realm == "" {
+		return "", errors.New("registry auth challenge without realm")
+	}
+	tokenURL, err := url.Parse(realm)
+	if err != nil {
+		return "", err
+	}
+	query := tokenURL.Query()
+	if service := challenge.params["service"]; service != "" {
+		query.Set("service", service)
+	}
+	if challengeScope := challenge.params["scope"]; challengeScope != "" {
+		query.Set("scope", challengeScope)
+	} else {
+		query.Set("scope", scope)
+	}
+	tokenURL.RawQuery = query.Encode()
+
+	req, err := http.NewRequest("GET", tokenURL.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	if r.user != "" && r.password != "" {
+		req.SetBasicAuth(r.user, r.password)
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("registry token http error: %v", resp.Status)
+	}
+	bodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	var tokenRes tokenResponse
+	err = json.Unmarshal(bodyBytes, &tokenRes)
+	if err != nil {
+		return "", err
+	}
+	value := tokenRes.Token
+	if value == "" {
+		value = tokenRes.AccessToken
+	}
+	if value == "" {
+		return "", errors.New("registry token response does not contain a token")
+	}
+
+	issuedAt := tokenRes.IssuedAt
+	if issuedAt.IsZero() {
+		issuedAt = r.now()
+	}
+	lifetime := defaultTokenLifetime
+	if tokenRes.ExpiresIn > 0 {
+		lifetime = time.Duration(tokenRes.ExpiresIn) * time.Second
+	}
+	r.tokens.set(scope, &token{
+		value:     value,
+		expiresAt: issuedAt.Add(lifetime - tokenExpiryLeeway),
+	})
+	return value, nil
+}
+
+// parseChallenge parses a 'WWW-Authenticate' header value, e.g.
+// 'Bearer realm="https://auth.example.com/token",service="registry.example.com"'.
+func parseChallenge(header string) (*authChallenge, error) {
+	header = strings.TrimSpace(header)
+	if header == "" {
+		return nil, errors.New("empty auth challenge")
+	}
+	scheme, rest, _ := strings.Cut(header, " ")
+	challenge := &authChallenge{
+		scheme: strings.ToLower(scheme),
+		params: map[string]string{},
+	}
+	for rest = strings.TrimSpace(rest); rest != ""; rest = strings.TrimSpace(rest) {
+		key, value, found := strings.Cut(rest, "=")
+		if !found {
+			return nil, fmt.Errorf("invalid auth challenge '%v'", header)
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+		if strings.HasPrefix(value, `"`) {
+			end := strings.Index(value[1:], `"`)
+			if end < 0 {
+				return nil, fmt.Errorf("invalid auth challenge '%v'", header)
+			}
+			challenge.params[key] = value[1 : end+1]
+			rest = value[end+2:]
+		} else {
+			var v string
+			v, rest, _ = strings.Cut(value, ",")
+			challenge.params[key] = strings.TrimSpace(v)
+		}
+		rest = strings.TrimPrefix(strings.TrimSpace(rest), ",")
+	}
+	return challenge, nil
+}
@@ -0,0 +1,193 @@
+package registry
+
+import (
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func tokenAuthClientMock(tokenRequests *int, tokenBody string) *httpClientMock {
+	return &httpClientMock{
+		doFunc: func(req *http.Request) (*http.Response, error) {
+			if req.URL.Host == "auth.example.com" {
+				*tokenRequests++
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Body:       io.NopCloser(strings.NewReader(tokenBody)),
+				}, nil
+			}
+			if req.Header.Get("Authorization") != "Bearer some-token" {
+				return &http.Response{
+					StatusCode: http.StatusUnauthorized,
+					Header: http.Header{
+						"Www-Authenticate": []string{`Bearer realm="https://auth.example.com/token",service="registry.example.com",scope="repository:some/image:pull"`},
+					},
+					Body: io.NopCloser(strings.NewReader("{}")),
+				}, nil
+			}
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(strings.NewReader(`{"name": "some/image", "tags": ["1.0.0"]}`)),
+			}, nil
+		},
+	}
+}
+
+func TestGetImageVersions_bearerToken(t *testing.T) {
+	r := NewRegistry(&Config{Registry: "https://registry.example.com"})
+	tokenRequests := 0
+	r.client = tokenAuthClientMock(&tokenRequests, `{"token": "some-token", "expires_in": 300}`)
+
+	for i := 0; i < 2; i++ {
+		actual, err := r.GetImageVersions("some/image")
+		if err != nil {
+			t.Fatalf("expected no error, got '%v'", err)
+		}
+		expected := []string{"1.0.0"}
+		if !reflect.DeepEqual(expected, actual) {
+			t.Errorf("expected %v, got %v", expected, actual)
+		}
+	}
+	if tokenRequests != 1 {
+		t.Errorf("expected the token to be requested once, got %d requests", tokenRequests)
+	}
+}
+
+func TestGetImageVersions_bearerTokenExpired(t *testing.T) {
+	r := NewRegistry(&Config{Registry: "https://registry.example.com"})
+	now := time.Date(2022, 12, 24, 0, 0, 0, 0, time.UTC)
+	r.now = func() time.Time { return now }
+	tokenRequests := 0
+	r.client = tokenAuthClientMock(&tokenRequests, `{"access_token": "some-token", "expires_in": 60}`)
+
+	_, err := r.GetImageVersions("some/image")
+	if err != nil {
+		t.Fatalf("expected no error, got '%v'", err)
+	}
+	now = now.Add(time.Minute)
+	_, err = r.GetImageVersions("some/image")
+	if err != nil {
+		t.Fatalf("expected no error, got '%v'", err)
+	}
+	if tokenRequests != 2 {
+		t.Errorf("expected the token to be requested twice, got %d requests", tokenRequests)
+	}
+}
+
+func TestGetImageVersions_bearerTokenRequest(t *testing.T) {
+	r := NewRegistry(&Config{
+		Registry: "https://registry.example.com",
+		User:     "user",
+		Password: "password",
+	})
+	var tokenReq *http.Request
+	tokenRequests := 0
+	mock := tokenAuthClientMock(&tokenRequests, `{"token": "some-token"}`)
+	r.client = &httpClientMock{
+		doFunc: func(req *http.Request) (*http.Response, error) {
+			if req.URL.Host == "auth.example.com" {
+				tokenReq = req
+			}
+			return mock.Do(req)
+		},
+	}
+	_, err := r.GetImageVersions("some/image")
+	if err != nil {
+		t.Fatalf("expected no error, got '%v'", err)
+	}
+	if tokenReq == nil {
+		t.Fatal("expected token request")
+	}
+	user, password, ok := tokenReq.BasicAuth()
+	if !ok || user != "user" || password != "password" {
+		t.Errorf("expected basic auth for token request, got '%v:%v'", user, password)
+	}
+	query := tokenReq.URL.Query()
+	if query.Get("service") != "registry.example.com" {
+		t.Errorf("expected service 'registry.example.com', got '%v'", query.Get("service"))
+	}
+	if query.Get("scope") != "repository:some/image:pull" {
+		t.Errorf("expected scope 'repository:some/image:pull', got '%v'", query.Get("scope"))
+	}
+}
+
+func TestGetImageVersions_bearerTokenError(t *testing.T) {
+	r := NewRegistry(&Config{Registry: "https://registry.example.com"})
+	tokenRequests := 0
+	r.client = tokenAuthClientMock(&tokenRequests, `{}`)
+	_, err := r.GetImageVersions("some/image")
+	if err == nil {
+		t.Error("expected error, got nil")
+	}
+}
+
+func TestParseChallenge(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   string
+		expected *authChallenge
+	}{
+		{
+			"bearer challenge",
+			`Bearer realm="https://auth.example.com/token",service="registry.example.com",scope="repository:some/image:pull"`,
+			&authChallenge{
+				scheme: "bearer",
+				params: map[string]string{
+					"realm":   "https://auth.example.com/token",
+					"service": "registry.example.com",
+					"scope":   "repository:some/image:pull",
+				},
+			},
+		},
+		{
+			"scope with comma",
+			`Bearer realm="https://auth.example.com/token", scope="repository:some/image:pull,push"`,
+			&authChallenge{
+				scheme: "bearer",
+				params: map[string]string{
+					"realm": "https://auth.example.com/token",
+					"scope": "repository:some/image:pull,push",
+				},
+			},
+		},
+		{
+			"unquoted values",
+			`Basic realm=registry, charset=UTF-8`,
+			&authChallenge{
+				scheme: "basic",
+				params: map[string]string{
+					"realm":   "registry",
+					"charset": "UTF-8",
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, err := parseChallenge(tt.header)
+			if err != nil {
+				t.Fatalf("expected no error, got '%v'", err)
+			}
+			if !reflect.DeepEqual(tt.expected, actual) {
+				t.Errorf("expected %+v, got %+v", tt.expected, actual)
+			}
+		})
+	}
+}
+
+func TestParseChallenge_invalid(t *testing.T) {
+	tests := []string{
+		"",
+		`Bearer realm`,
+		`Bearer realm="unterminated`,
+	}
+	for _, header := range tests {
+		_, err := parseChallenge(header)
+		if err == nil {
+			t.Errorf("expected error for '%v'", header)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package registry
+
+import (
+	"sync"
+)
+
+// lookup is implemented by the registries that can be cached (see Registry
+// and Router).
+type lookup interface {
+	GetImageVersions(imageName string) ([]string, error)
+	GetImageDigest(imageName string, tag string) (string, error)
+}
+
+// Cache deduplicates the lookups of a registry within a run. Concurrent
+// lookups of the same image share a single request and the result (including
+// an error) is kept for later lookups, so that the tags of an image used by
+// several services or files are only fetched once. The image names are
+// expected to be normalized by the caller (e.g. 'library/redis').
+type Cache struct {
+	reg     lookup
+	mu      sync.Mutex
+	entries map[string]*cacheEntry
+}
+
+type cacheEntry struct {
+	once  sync.Once
+	value interface{}
+	err   error
+}
+
+func NewCache(reg lookup) *Cache {
+	return &Cache{
+		reg:     reg,
+		entries: map[string]*cacheEntry{},
+	}
+}
+
+func (c *Cache) GetImageVersions(imageName string) ([]string, error) {
+	value, err := c.lookup("versions "+imageName, func() (interface{}, error) {
+		return c.reg.GetImageVersions(imageName)
+	})
+	versions, _ := value.([]string)
+	// the callers must not share the cached slice
+	return append([]string(nil), versions...), err
+}
+
+func (c *Cache) GetImageDigest(imageName string, tag string) (string, error) {
+	value, err := c.lookup("digest "+imageName+":"+tag, func() (interface{}, error) {
+		return c.reg.GetImageDigest(imageName, tag)
+	})
+	digest, _ := value.(string)
+	return digest, err
+}
+
+// lookup returns the cached result for the key. The first caller runs the
+// lookup, all other callers wait for its result.
+func (c *Cache) lookup(key string, fn func() (interface{}, error)) (interface{}, error) {
+	c.mu.Lock()
+	e, ok := c.entries[key]
+	if !ok {
+		e = &cacheEntry{}
+		c.entries[key] = e
+	}
+	c.mu.Unlock()
+
+	e.once.Do(func() {
+		e.value, e.err = fn()
+	})
+	return e.value, e.err
+}
@@ -0,0 +1,110 @@
+package registry
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+type lookupMock struct {
+	mu       sync.Mutex
+	calls    map[string]int
+	versions []string
+	err      error
+}
+
+func (l *lookupMock) count(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.calls == nil {
+		l.calls = map[string]int{}
+	}
+	l.calls[key]++
+}
+
+func (l *lookupMock) GetImageVersions(imageName string) ([]string, error) {
+	l.count(imageName)
+	// give the concurrent lookups time to pile up
+	time.Sleep(10 * time.Millisecond)
+	return l.versions, l.err
+}
+
+func (l *lookupMock) GetImageDigest(imageName string, tag string) (string, error) {
+	l.count(imageName + ":" + tag)
+	return "sha256:" + tag, l.err
+}
+
+func TestCache_GetImageVersions(t *testing.T) {
+	l := &lookupMock{versions: []string{"1.0.0", "2.0.0"}}
+	c := NewCache(l)
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			versions, err := c.GetImageVersions("library/redis")
+			if err != nil {
+				t.Errorf("expected no error, got '%v'", err)
+			}
+			if !reflect.DeepEqual(versions, l.versions) {
+				t.Errorf("expected '%v', got '%v'", l.versions, versions)
+			}
+		}()
+	}
+	wg.Wait()
+	_, err := c.GetImageVersions("library/alpine")
+	if err != nil {
+		t.Fatalf("expected no error, got '%v'", err)
+	}
+
+	expected := map[string]int{"library/redis": 1, "library/alpine": 1}
+	if !reflect.DeepEqual(l.calls, expected) {
+		t.Errorf("expected '%v', got '%v'", expected, l.calls)
+	}
+}
+
+func TestCache_GetImageVersions_copy(t *testing.T) {
+	l := &lookupMock{versions: []string{"1.0.0"}}
+	c := NewCache(l)
+	versions, _ := c.GetImageVersions("library/redis")
+	versions[0] = "changed"
+	versions, _ = c.GetImageVersions("library/redis")
+	if versions[0] != "1.0.0" {
+		t.Errorf("expected '1.0.0', got '%v'", versions[0])
+	}
+}
+
+func TestCache_GetImageVersions_error(t *testing.T) {
+	l := &lookupMock{err: errors.New("rate limit")}
+	c := NewCache(l)
+	for i := 0; i < 2; i++ {
+		_, err := c.GetImageVersions("library/redis")
+		if err == nil || err.Error() != "rate limit" {
+			t.Errorf("expected 'rate limit', got '%v'", err)
+		}
+	}
+	if l.calls["library/redis"] != 1 {
+		t.Errorf("expected 1 call, got %v", l.calls["library/redis"])
+	}
+}
+
+func TestCache_GetImageDigest(t *testing.T) {
+	l := &lookupMock{}
+	c := NewCache(l)
+	for _, tag := range []string{"7.0.5", "7.0.5", "7.0.4"} {
+		digest, err := c.GetImageDigest("library/redis", tag)
+		if err != nil {
+			t.Fatalf("expected no error, got '%v'", err)
+		}
+		if digest != "sha256:"+tag {
+			t.Errorf("expected 'sha256:%v', got '%v'", tag, digest)
+		}
+	}
+	expected := map[string]int{"library/redis:7.0.5": 1, "library/redis:7.0.4": 1}
+	if !reflect.DeepEqual(l.calls, expected) {
+		t.Errorf("expected '%v', got '%v'", expected, l.calls)
+	}
+}
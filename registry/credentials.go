@@ -0,0 +1,177 @@
+package registry
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// dockerHubServerAddress is the key the Docker CLI uses for Docker Hub
+// credentials.
+const dockerHubServerAddress = "https://index.docker.io/v1/"
+
+type dockerConfig struct {
+	Auths       map[string]dockerAuth `json:"auths"`
+	CredsStore  string                `json:"credsStore"`
+	CredHelpers map[string]string     `json:"credHelpers"`
+}
+
+type dockerAuth struct {
+	Auth     string `json:"auth"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type helperCredentials struct {
+	Username string `json:"Username"`
+	Secret   string `json:"Secret"`
+}
+
+// execCredentialHelper runs 'docker-credential-<helper> get' with the server
+// address on stdin and returns the output. It is a variable so that it can be
+// replaced in tests.
+var execCredentialHelper = func(helper string, serverAddress string) ([]byte, error) {
+	cmd := exec.Command("docker-credential-"+helper, "get")
+	cmd.Stdin = strings.NewReader(serverAddress)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if err != nil {
+		// helpers report errors like 'credentials not found' on stdout
+		msg := strings.TrimSpace(stdout.String() + stderr.String())
+		return nil, fmt.Errorf("credential helper '%v' failed: %v: %v", helper, err, msg)
+	}
+	return stdout.Bytes(), nil
+}
+
+// defaultDockerConfigPath returns the path of the Docker CLI config file, which
+// is located in '$DOCKER_CONFIG' or '~/.docker'.
+func defaultDockerConfigPath() string {
+	dir := os.Getenv("DOCKER_CONFIG")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".docker")
+	}
+	return filepath.Join(dir, "config.json")
+}
+
+// loadDockerConfig reads the Docker CLI config file. A missing file results in
+// an empty config.
+func loadDockerConfig(path string) (*dockerConfig, error) {
+	cfg := &dockerConfig{}
+	if path == "" {
+		return cfg, nil
+	}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(b, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse Docker config '%v': %v", path, err)
+	}
+	return cfg, nil
+}
+
+// credentials returns the user and password for the given registry URL. The
+// credential helper configured for the host is preferred, then the default
+// credentials store and finally the credentials stored in 'auths'.
+func (c *dockerConfig) credentials(registryURL string) (user string, password string, err error) {
+	host, serverAddress := credentialKeys(registryURL)
+
+	helper := c.CredHelpers[host]
+	if helper == "" {
+		helper = c.CredHelpers[serverAddress]
+	}
+	if helper == "" {
+		helper = c.CredsStore
+	}
+	if helper != "" {
+		user, password, err = getHelperCredentials(helper, serverAddress)
+		if err != nil || user != "" {
+			return
+		}
+	}
+
+	for key, auth := range c.Auths {
+		if key != serverAddress && normalizeCredentialKey(key) != host {
+			continue
+		}
+		if auth.Username != "" && auth.Password != "" {
+			return auth.Username, auth.Password, nil
+		}
+		if auth.Auth != "" {
+			return decodeAuth(auth.Auth)
+		}
+	}
+	return "", "", nil
+}
+
+func getHelperCredentials(helper string, serverAddress string) (string, string, error) {
+	out, err := execCredentialHelper(helper, serverAddress)
+	if err != nil {
+		if strings.Contains(strings.ToLower(err.Error()), "credentials not found") {
+			return "", "", nil
+		}
+		return "", "", err
+	}
+	var creds helperCredentials
+	err = json.Unmarshal(out, &creds)
+	if err != nil {
+		return "", "", fmt.Errorf("could not parse output of credential helper '%v': %v", helper, err)
+	}
+	return creds.Username, creds.Secret, nil
+}
+
+func decodeAuth(auth string) (string, string, error) {
+	b, err := base64.StdEncoding.DecodeString(auth)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid auth in Docker config: %v", err)
+	}
+	user, password, found := strings.Cut(string(b), ":")
+	if !found {
+		return "", "", errors.New("invalid auth in Docker config: missing ':'")
+	}
+	return user, password, nil
+}
+
+// credentialKeys returns the host and the server address under which the
+// Docker CLI stores the credentials for the given registry URL.
+func credentialKeys(registryURL string) (host string, serverAddress string) {
+	host = normalizeCredentialKey(registryURL)
+	if host == "hub.docker.com" || isDockerHubHost(host) {
+		return "index.docker.io", dockerHubServerAddress
+	}
+	return host, host
+}
+
+// normalizeCredentialKey strips the scheme and path from a key of the Docker
+// config, e.g. 'https://index.docker.io/v1/' becomes 'index.docker.io'.
+func normalizeCredentialKey(key string) string {
+	if strings.Contains(key, "://") {
+		u, err := url.Parse(key)
+		if err == nil {
+			return u.Host
+		}
+	}
+	host, _, _ := strings.Cut(key, "/")
+	return host
+}
+
+func isDockerHubHost(host string) bool {
+	return host == "docker.io" || host == "index.docker.io" || host == "registry-1.docker.io"
+}
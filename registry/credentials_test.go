@@ -0,0 +1,216 @@
+package registry
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeDockerConfig(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "config.json")
+	err := os.WriteFile(path, []byte(content), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func mockCredentialHelper(t *testing.T, fn func(helper string, serverAddress string) ([]byte, error)) {
+	orig := execCredentialHelper
+	execCredentialHelper = fn
+	t.Cleanup(func() { execCredentialHelper = orig })
+}
+
+func TestLoadDockerConfig_missingFile(t *testing.T) {
+	cfg, err := loadDockerConfig(filepath.Join(t.TempDir(), "config.json"))
+	if err != nil {
+		t.Fatalf("expected no error, got '%v'", err)
+	}
+	if cfg == nil {
+		t.Fatal("expected empty config")
+	}
+}
+
+func TestLoadDockerConfig_invalidFile(t *testing.T) {
+	_, err := loadDockerConfig(writeDockerConfig(t, "invalid"))
+	if err == nil {
+		t.Error("expected error, got nil")
+	}
+}
+
+func TestDockerConfigCredentials(t *testing.T) {
+	mockCredentialHelper(t, func(helper string, serverAddress string) ([]byte, error) {
+		switch helper {
+		case "ecr-login":
+			return []byte(`{"ServerURL": "` + serverAddress + `", "Username": "AWS", "Secret": "ecr-secret"}`), nil
+		case "store":
+			if serverAddress == "store.example.com" {
+				return []byte(`{"Username": "store-user", "Secret": "store-secret"}`), nil
+			}
+			return nil, errors.New("credentials not found in native keychain")
+		}
+		return nil, errors.New("unknown helper")
+	})
+
+	tests := []struct {
+		name             string
+		config           string
+		registryURL      string
+		expectedUser     string
+		expectedPassword string
+	}{
+		{
+			"auth from auths",
+			`{"auths": {"registry.example.com": {"auth": "dXNlcjpwYXNzd29yZA=="}}}`,
+			"https://registry.example.com",
+			"user",
+			"password",
+		},
+		{
+			"username and password from auths",
+			`{"auths": {"https://registry.example.com/v2/": {"username": "user", "password": "password"}}}`,
+			"https://registry.example.com",
+			"user",
+			"password",
+		},
+		{
+			"Docker Hub from auths",
+			`{"auths": {"https://index.docker.io/v1/": {"auth": "dXNlcjpwYXNzd29yZA=="}}}`,
+			"https://hub.docker.com",
+			"user",
+			"password",
+		},
+		{
+			"no credentials for registry",
+			`{"auths": {"other.example.com": {"auth": "dXNlcjpwYXNzd29yZA=="}}}`,
+			"https://registry.example.com",
+			"",
+			"",
+		},
+		{
+			"credential helper for registry",
+			`{"credHelpers": {"123.dkr.ecr.eu-central-1.amazonaws.com": "ecr-login"}}`,
+			"https://123.dkr.ecr.eu-central-1.amazonaws.com",
+			"AWS",
+			"ecr-secret",
+		},
+		{
+			"credentials store",
+			`{"credsStore": "store"}`,
+			"https://store.example.com",
+			"store-user",
+			"store-secret",
+		},
+		{
+			"credentials store without credentials falls back to auths",
+			`{"credsStore": "store", "auths": {"registry.example.com": {"auth": "dXNlcjpwYXNzd29yZA=="}}}`,
+			"https://registry.example.com",
+			"user",
+			"password",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := loadDockerConfig(writeDockerConfig(t, tt.config))
+			if err != nil {
+				t.Fatal(err)
+			}
+			user, password, err := cfg.credentials(tt.registryURL)
+			if err != nil {
+				t.Fatalf("expected no error, got '%v'", err)
+			}
+			if tt.expectedUser != user || tt.expectedPassword != password {
+				t.Errorf("expected '%v:%v', got '%v:%v'", tt.expectedUser, tt.expectedPassword, user, password)
+			}
+		})
+	}
+}
+
+func TestDockerConfigCredentials_helperError(t *testing.T) {
+	mockCredentialHelper(t, func(helper string, serverAddress string) ([]byte, error) {
+		return nil, errors.New("some error")
+	})
+	cfg := &dockerConfig{CredsStore: "store"}
+	_, _, err := cfg.credentials("https://registry.example.com")
+	if err == nil {
+		t.Error("expected error, got nil")
+	}
+}
+
+func TestDockerConfigCredentials_invalidAuth(t *testing.T) {
+	cfg := &dockerConfig{
+		Auths: map[string]dockerAuth{
+			"registry.example.com": {Auth: "invalid"},
+		},
+	}
+	_, _, err := cfg.credentials("https://registry.example.com")
+	if err == nil {
+		t.Error("expected error, got nil")
+	}
+}
+
+func TestGetImageVersions_dockerConfigCredentials(t *testing.T) {
+	r := NewRegistry(&Config{
+		Registry:     "https://registry.example.com",
+		DockerConfig: writeDockerConfig(t, `{"auths": {"registry.example.com": {"auth": "dXNlcjpwYXNzd29yZA=="}}}`),
+	})
+	var user, password string
+	r.client = &httpClientMock{
+		doFunc: func(req *http.Request) (*http.Response, error) {
+			user, password, _ = req.BasicAuth()
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(strings.NewReader(`{"name": "some/image", "tags": ["1.0.0"]}`)),
+			}, nil
+		},
+	}
+	_, err := r.GetImageVersions("some/image")
+	if err != nil {
+		t.Fatalf("expected no error, got '%v'", err)
+	}
+	if user != "user" || password != "password" {
+		t.Errorf("expected basic auth 'user:password', got '%v:%v'", user, password)
+	}
+}
+
+func TestGetImageVersions_explicitCredentials(t *testing.T) {
+	r := NewRegistry(&Config{
+		Registry:     "https://registry.example.com",
+		User:         "flag-user",
+		Password:     "flag-password",
+		DockerConfig: writeDockerConfig(t, `{"auths": {"registry.example.com": {"auth": "dXNlcjpwYXNzd29yZA=="}}}`),
+	})
+	var user, password string
+	r.client = &httpClientMock{
+		doFunc: func(req *http.Request) (*http.Response, error) {
+			user, password, _ = req.BasicAuth()
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(strings.NewReader(`{"name": "some/image", "tags": ["1.0.0"]}`)),
+			}, nil
+		},
+	}
+	_, err := r.GetImageVersions("some/image")
+	if err != nil {
+		t.Fatalf("expected no error, got '%v'", err)
+	}
+	if user != "flag-user" || password != "flag-password" {
+		t.Errorf("expected basic auth 'flag-user:flag-password', got '%v:%v'", user, password)
+	}
+}
+
+func TestGetImageVersions_invalidDockerConfig(t *testing.T) {
+	r := NewRegistry(&Config{
+		Registry:     "https://registry.example.com",
+		DockerConfig: writeDockerConfig(t, "invalid"),
+	})
+	r.client = &httpClientMock{}
+	_, err := r.GetImageVersions("some/image")
+	if err == nil {
+		t.Error("expected error, got nil")
+	}
+}
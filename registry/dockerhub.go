@@ -0,0 +1,66 @@
+package registry
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+)
+
+type tagDetailResponse struct {
+	Digest string `json:"digest"`
+}
+
+type tagResponse struct {
+	Next    string `json:"next"`
+	Results []struct {
+		Name string `json:"name"`
+	} `json:"results"`
+}
+
+// getDockerHubTags lists the tags of an image via the Docker Hub API and
+// follows the 'next' links until all pages (or the configured maximum number
+// of pages) are read.
+func (r *Registry) getDockerHubTags(imageName string) ([]string, error) {
+	reqURL := r.registry + "/v2/repositories/" + imageName + "/tags/?ordering=last_updated&page=1&page_size=100" // 100 is the max page_size
+	var imgVersions []string
+	for page := 1; reqURL != "" && !r.exceedsMaxPages(page); page++ {
+		_, bodyBytes, err := r.get(reqURL, imageName)
+		if err != nil {
+			return nil, err
+		}
+
+		var tagRes tagResponse
+		err = json.Unmarshal(bodyBytes, &tagRes)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, t := range tagRes.Results {
+			imgVersions = append(imgVersions, t.Name)
+		}
+		if tagRes.Next == reqURL {
+			break
+		}
+		reqURL = tagRes.Next
+	}
+	return imgVersions, nil
+}
+
+// getDockerHubDigest returns the digest of the given tag via the Docker Hub API.
+func (r *Registry) getDockerHubDigest(imageName string, tag string) (string, error) {
+	reqURL := r.registry + "/v2/repositories/" + imageName + "/tags/" + url.PathEscape(tag)
+	_, bodyBytes, err := r.get(reqURL, imageName)
+	if err != nil {
+		return "", err
+	}
+
+	var tagRes tagDetailResponse
+	err = json.Unmarshal(bodyBytes, &tagRes)
+	if err != nil {
+		return "", err
+	}
+	if tagRes.Digest == "" {
+		return "", fmt.Errorf("could not find digest for '%v:%v'", imageName, tag)
+	}
+	return tagRes.Digest, nil
+}
@@ -0,0 +1,132 @@
+package registry
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func dockerHubPagesMock(pages int, reqURLs *[]string) *httpClientMock {
+	return &httpClientMock{
+		doFunc: func(req *http.Request) (*http.Response, error) {
+			*reqURLs = append(*reqURLs, req.URL.String())
+			page := 1
+			fmt.Sscan(req.URL.Query().Get("page"), &page)
+			next := "null"
+			if page < pages {
+				next = fmt.Sprintf(`"https://hub.docker.com/v2/repositories/some/image/tags/?page=%d&page_size=100"`, page+1)
+			}
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body: io.NopCloser(strings.NewReader(fmt.Sprintf(`{
+	"next": %s,
+	"results": [{"name": "%d.0.0"}]
+}`, next, page))),
+			}, nil
+		},
+	}
+}
+
+func TestGetImageVersions_dockerHubPagination(t *testing.T) {
+	r := NewRegistry(&Config{})
+	var reqURLs []string
+	r.client = dockerHubPagesMock(3, &reqURLs)
+	actual, err := r.GetImageVersions("some/image")
+	if err != nil {
+		t.Fatalf("expected no error, got '%v'", err)
+	}
+	expected := []string{"1.0.0", "2.0.0", "3.0.0"}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+	if len(reqURLs) != 3 {
+		t.Errorf("expected 3 requests, got %d", len(reqURLs))
+	}
+}
+
+func TestGetImageVersions_dockerHubMaxPages(t *testing.T) {
+	r := NewRegistry(&Config{MaxPages: 2})
+	var reqURLs []string
+	r.client = dockerHubPagesMock(5, &reqURLs)
+	actual, err := r.GetImageVersions("some/image")
+	if err != nil {
+		t.Fatalf("expected no error, got '%v'", err)
+	}
+	expected := []string{"1.0.0", "2.0.0"}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+	if len(reqURLs) != 2 {
+		t.Errorf("expected 2 requests, got %d", len(reqURLs))
+	}
+}
+
+func TestGetImageVersions_dockerHubPaginationError(t *testing.T) {
+	r := NewRegistry(&Config{})
+	r.client = &httpClientMock{
+		doFunc: func(req *http.Request) (*http.Response, error) {
+			if req.URL.Query().Get("page") == "2" {
+				return &http.Response{
+					StatusCode: http.StatusTooManyRequests,
+					Status:     "429 Too Many Requests",
+					Body:       io.NopCloser(strings.NewReader("{}")),
+				}, nil
+			}
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body: io.NopCloser(strings.NewReader(`{
+	"next": "https://hub.docker.com/v2/repositories/some/image/tags/?page=2&page_size=100",
+	"results": [{"name": "1.0.0"}]
+}`)),
+			}, nil
+		},
+	}
+	_, err := r.GetImageVersions("some/image")
+	if err == nil {
+		t.Error("expected error, got nil")
+	}
+}
+
+func TestGetImageDigest_dockerHub(t *testing.T) {
+	r := NewRegistry(&Config{})
+	var reqURL string
+	r.client = &httpClientMock{
+		doFunc: func(req *http.Request) (*http.Response, error) {
+			reqURL = req.URL.String()
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(strings.NewReader(`{"name": "1.0.0", "digest": "sha256:abc"}`)),
+			}, nil
+		},
+	}
+	actual, err := r.GetImageDigest("library/alpine", "1.0.0")
+	if err != nil {
+		t.Fatalf("expected no error, got '%v'", err)
+	}
+	expectedURL := "https://hub.docker.com/v2/repositories/library/alpine/tags/1.0.0"
+	if reqURL != expectedURL {
+		t.Errorf("expected request to '%v', got '%v'", expectedURL, reqURL)
+	}
+	if actual != "sha256:abc" {
+		t.Errorf("expected '%v', got '%v'", "sha256:abc", actual)
+	}
+}
+
+func TestGetImageDigest_dockerHubNoDigest(t *testing.T) {
+	r := NewRegistry(&Config{})
+	r.client = &httpClientMock{
+		doFunc: func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(strings.NewReader(`{"name": "1.0.0"}`)),
+			}, nil
+		},
+	}
+	_, err := r.GetImageDigest("library/alpine", "1.0.0")
+	if err == nil {
+		t.Error("expected error, got nil")
+	}
+}
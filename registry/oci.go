@@ -0,0 +1,102 @@
+package registry
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// manifestMediaTypes are accepted when requesting a manifest, so that the
+// registry returns the manifest list (or image index) for multi platform
+// images instead of converting it to a single manifest.
+var manifestMediaTypes = []string{
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+}
+
+type ociTagResponse struct {
+	Name string   `json:"name"`
+	Tags []string `json:"tags"`
+}
+
+var reLinkNext = regexp.MustCompile(`<([^>]+)>\s*;\s*rel="?next"?`)
+
+// getOCITags lists the tags of an image via the OCI Distribution API
+// ('/v2/<name>/tags/list') and follows the pagination given by the 'Link'
+// header until all pages (or the configured maximum number of pages) are read.
+func (r *Registry) getOCITags(imageName string) ([]string, error) {
+	reqURL := r.registry + "/v2/" + imageName + "/tags/list?n=1000"
+	var imgVersions []string
+	for page := 1; reqURL != "" && !r.exceedsMaxPages(page); page++ {
+		resp, bodyBytes, err := r.get(reqURL, imageName)
+		if err != nil {
+			return nil, err
+		}
+
+		var tagRes ociTagResponse
+		err = json.Unmarshal(bodyBytes, &tagRes)
+		if err != nil {
+			return nil, err
+		}
+		imgVersions = append(imgVersions, tagRes.Tags...)
+
+		nextURL, err := getNextLink(reqURL, resp.Header)
+		if err != nil {
+			return nil, err
+		}
+		if nextURL == reqURL {
+			break
+		}
+		reqURL = nextURL
+	}
+	return imgVersions, nil
+}
+
+// getOCIDigest returns the digest of the given tag, which is read from the
+// 'Docker-Content-Digest' header of a HEAD request on the manifest. If the
+// registry does not return the header, the manifest is fetched and its digest
+// calculated.
+func (r *Registry) getOCIDigest(imageName string, tag string) (string, error) {
+	reqURL := r.registry + "/v2/" + imageName + "/manifests/" + url.PathEscape(tag)
+	header := http.Header{
+		"Accept": []string{strings.Join(manifestMediaTypes, ", ")},
+	}
+	resp, _, err := r.request("HEAD", reqURL, imageName, header)
+	if err != nil {
+		return "", err
+	}
+	if digest := resp.Header.Get("Docker-Content-Digest"); digest != "" {
+		return digest, nil
+	}
+
+	_, bodyBytes, err := r.request("GET", reqURL, imageName, header)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(bodyBytes)
+	return "sha256:" + hex.EncodeToString(sum[:]), nil
+}
+
+// getNextLink returns the absolute URL of the next page given by the 'Link'
+// header or an empty string if there is no next page.
+func getNextLink(reqURL string, header http.Header) (string, error) {
+	matches := reLinkNext.FindStringSubmatch(header.Get("Link"))
+	if matches == nil {
+		return "", nil
+	}
+	base, err := url.Parse(reqURL)
+	if err != nil {
+		return "", err
+	}
+	next, err := base.Parse(matches[1])
+	if err != nil {
+		return "", err
+	}
+	return next.String(), nil
+}
@@ -0,0 +1,252 @@
+package registry
+
+import (
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGetImageVersions_oci(t *testing.T) {
+	r := NewRegistry(&Config{Registry: "https://registry.example.com"})
+	var reqURL string
+	r.client = &httpClientMock{
+		doFunc: func(req *http.Request) (*http.Response, error) {
+			reqURL = req.URL.String()
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body: io.NopCloser(strings.NewReader(`{
+	"name": "some/image",
+	"tags": ["1.0.0", "1.1.0"]
+}`)),
+			}, nil
+		},
+	}
+	actual, err := r.GetImageVersions("some/image")
+	if err != nil {
+		t.Fatalf("expected no error, got '%v'", err)
+	}
+	expectedURL := "https://registry.example.com/v2/some/image/tags/list?n=1000"
+	if reqURL != expectedURL {
+		t.Errorf("expected request to '%v', got '%v'", expectedURL, reqURL)
+	}
+	expected := []string{"1.0.0", "1.1.0"}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+}
+
+func TestGetImageVersions_ociPagination(t *testing.T) {
+	r := NewRegistry(&Config{Registry: "https://registry.example.com"})
+	var reqURLs []string
+	r.client = &httpClientMock{
+		doFunc: func(req *http.Request) (*http.Response, error) {
+			reqURLs = append(reqURLs, req.URL.String())
+			if req.URL.Query().Get("last") == "" {
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Header: http.Header{
+						"Link": []string{`</v2/some/image/tags/list?n=1000&last=1.1.0>; rel="next"`},
+					},
+					Body: io.NopCloser(strings.NewReader(`{"name": "some/image", "tags": ["1.0.0", "1.1.0"]}`)),
+				}, nil
+			}
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(strings.NewReader(`{"name": "some/image", "tags": ["2.0.0"]}`)),
+			}, nil
+		},
+	}
+	actual, err := r.GetImageVersions("some/image")
+	if err != nil {
+		t.Fatalf("expected no error, got '%v'", err)
+	}
+	expected := []string{"1.0.0", "1.1.0", "2.0.0"}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+	expectedURLs := []string{
+		"https://registry.example.com/v2/some/image/tags/list?n=1000",
+		"https://registry.example.com/v2/some/image/tags/list?n=1000&last=1.1.0",
+	}
+	if !reflect.DeepEqual(expectedURLs, reqURLs) {
+		t.Errorf("expected requests %v, got %v", expectedURLs, reqURLs)
+	}
+}
+
+func TestGetImageVersions_ociHttpError(t *testing.T) {
+	r := NewRegistry(&Config{Registry: "https://registry.example.com"})
+	r.client = &httpClientMock{
+		doFunc: func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusNotFound,
+				Body:       io.NopCloser(strings.NewReader("{}")),
+			}, nil
+		},
+	}
+	_, err := r.GetImageVersions("some/image")
+	if err == nil {
+		t.Error("expected error, got nil")
+	}
+}
+
+func TestGetImageVersions_ociNoVersionsFound(t *testing.T) {
+	r := NewRegistry(&Config{Registry: "https://registry.example.com"})
+	r.client = &httpClientMock{
+		doFunc: func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(strings.NewReader(`{"name": "some/image", "tags": null}`)),
+			}, nil
+		},
+	}
+	_, err := r.GetImageVersions("some/image")
+	if err == nil {
+		t.Error("expected error, got nil")
+	}
+}
+
+func TestGetNextLink(t *testing.T) {
+	tests := []struct {
+		name     string
+		link     string
+		expected string
+	}{
+		{
+			"no link header",
+			"",
+			"",
+		},
+		{
+			"relative link",
+			`</v2/some/image/tags/list?n=2&last=b>; rel="next"`,
+			"https://registry.example.com/v2/some/image/tags/list?n=2&last=b",
+		},
+		{
+			"absolute link",
+			`<https://other.example.com/v2/some/image/tags/list?last=b>; rel=next`,
+			"https://other.example.com/v2/some/image/tags/list?last=b",
+		},
+		{
+			"other relation",
+			`</v2/some/image/tags/list?n=2&last=b>; rel="prev"`,
+			"",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			if tt.link != "" {
+				header.Set("Link", tt.link)
+			}
+			actual, err := getNextLink("https://registry.example.com/v2/some/image/tags/list", header)
+			if err != nil {
+				t.Fatalf("expected no error, got '%v'", err)
+			}
+			if tt.expected != actual {
+				t.Errorf("expected '%v', got '%v'", tt.expected, actual)
+			}
+		})
+	}
+}
+
+func TestGetImageVersions_ociMaxPages(t *testing.T) {
+	r := NewRegistry(&Config{Registry: "https://registry.example.com", MaxPages: 1})
+	requests := 0
+	r.client = &httpClientMock{
+		doFunc: func(req *http.Request) (*http.Response, error) {
+			requests++
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header: http.Header{
+					"Link": []string{`</v2/some/image/tags/list?n=1000&last=1.0.0>; rel="next"`},
+				},
+				Body: io.NopCloser(strings.NewReader(`{"name": "some/image", "tags": ["1.0.0"]}`)),
+			}, nil
+		},
+	}
+	_, err := r.GetImageVersions("some/image")
+	if err != nil {
+		t.Fatalf("expected no error, got '%v'", err)
+	}
+	if requests != 1 {
+		t.Errorf("expected 1 request, got %d", requests)
+	}
+}
+
+func TestGetImageDigest_oci(t *testing.T) {
+	r := NewRegistry(&Config{Registry: "https://registry.example.com"})
+	var method, reqURL, accept string
+	r.client = &httpClientMock{
+		doFunc: func(req *http.Request) (*http.Response, error) {
+			method, reqURL, accept = req.Method, req.URL.String(), req.Header.Get("Accept")
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header: http.Header{
+					"Docker-Content-Digest": []string{"sha256:abc"},
+				},
+				Body: io.NopCloser(strings.NewReader("")),
+			}, nil
+		},
+	}
+	actual, err := r.GetImageDigest("some/image", "1.0.0")
+	if err != nil {
+		t.Fatalf("expected no error, got '%v'", err)
+	}
+	if method != "HEAD" {
+		t.Errorf("expected method 'HEAD', got '%v'", method)
+	}
+	expectedURL := "https://registry.example.com/v2/some/image/manifests/1.0.0"
+	if reqURL != expectedURL {
+		t.Errorf("expected request to '%v', got '%v'", expectedURL, reqURL)
+	}
+	if !strings.Contains(accept, "application/vnd.oci.image.index.v1+json") {
+		t.Errorf("expected Accept header to contain the OCI image index, got '%v'", accept)
+	}
+	if actual != "sha256:abc" {
+		t.Errorf("expected '%v', got '%v'", "sha256:abc", actual)
+	}
+}
+
+func TestGetImageDigest_ociWithoutDigestHeader(t *testing.T) {
+	r := NewRegistry(&Config{Registry: "https://registry.example.com"})
+	var methods []string
+	r.client = &httpClientMock{
+		doFunc: func(req *http.Request) (*http.Response, error) {
+			methods = append(methods, req.Method)
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(strings.NewReader("manifest")),
+			}, nil
+		},
+	}
+	actual, err := r.GetImageDigest("some/image", "1.0.0")
+	if err != nil {
+		t.Fatalf("expected no error, got '%v'", err)
+	}
+	expectedMethods := []string{"HEAD", "GET"}
+	if !reflect.DeepEqual(expectedMethods, methods) {
+		t.Errorf("expected requests %v, got %v", expectedMethods, methods)
+	}
+	expected := "sha256:05b3abf2579a5eb66403cd78be557fd860633a1fe2103c7642030defe32c657f"
+	if actual != expected {
+		t.Errorf("expected '%v', got '%v'", expected, actual)
+	}
+}
+
+func TestGetImageDigest_ociHttpError(t *testing.T) {
+	r := NewRegistry(&Config{Registry: "https://registry.example.com"})
+	r.client = &httpClientMock{
+		doFunc: func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusNotFound,
+				Body:       io.NopCloser(strings.NewReader("")),
+			}, nil
+		},
+	}
+	_, err := r.GetImageDigest("some/image", "1.0.0")
+	if err == nil {
+		t.Error("expected error, got nil")
+	}
+}
@@ -1,84 +1,207 @@
 package registry
 
 import (
-	"encoding/base64"
-	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
 )
 
+const dockerHubURL = "https://hub.docker.com"
+
 type Config struct {
 	Registry string
 	User     string
 	Password string
+	// DockerConfig is the path of the Docker CLI config file, which is used
+	// to look up the credentials if no user and password are given. If empty,
+	// the default location is used.
+	DockerConfig string
+	// MaxPages limits the number of tag list pages that are requested per
+	// image. Zero means no limit.
+	MaxPages int
 }
 
 type Registry struct {
-	registry   string
-	client     httpClient
-	httpHeader http.Header
+	registry    string
+	isDockerHub bool
+	maxPages    int
+	user        string
+	password    string
+	dockerCfg   string
+	// explicitCreds is set if user and password are given, the Docker config
+	// is not read then
+	explicitCreds bool
+	credsOnce     sync.Once
+	credsErr      error
+	client        httpClient
+	httpHeader    http.Header
+	tokens        *tokenCache
+	now           func() time.Time
 }
 
 type httpClient interface {
 	Do(req *http.Request) (*http.Response, error)
 }
 
-type tagResponse struct {
-	Results []struct {
-		Name string `json:"name"`
-	} `json:"results"`
-}
-
 func NewRegistry(cfg *Config) *Registry {
 	reg := &Registry{
-		registry:   "https://hub.docker.com",
+		registry:   dockerHubURL,
 		client:     &http.Client{},
 		httpHeader: http.Header{},
+		tokens:     newTokenCache(),
+		now:        time.Now,
 	}
 	if cfg.Registry != "" {
-		reg.registry = cfg.Registry
+		reg.registry = normalizeURL(cfg.Registry)
 	}
+	reg.isDockerHub = isDockerHub(reg.registry)
+	reg.maxPages = cfg.MaxPages
 	if cfg.User != "" && cfg.Password != "" {
-		basicAuth := base64.StdEncoding.EncodeToString([]byte(cfg.User + ":" + cfg.Password))
-		reg.httpHeader.Add("Authorization", "Basic "+basicAuth)
+		reg.user = cfg.User
+		reg.password = cfg.Password
+		reg.explicitCreds = true
+	}
+	reg.dockerCfg = cfg.DockerConfig
+	if reg.dockerCfg == "" {
+		reg.dockerCfg = defaultDockerConfigPath()
 	}
 	reg.httpHeader.Add("Accept", "application/json")
 	return reg
 }
 
+// GetImageVersions returns all tags of the given image. Docker Hub is queried
+// via its proprietary API, every other registry via the OCI Distribution API.
 func (r *Registry) GetImageVersions(imageName string) ([]string, error) {
-	req, err := http.NewRequest("GET", r.registry+"/v2/repositories/"+imageName+"/tags/?ordering=last_updated&page=1&page_size=100", nil) // 100 is the max page_size
+	var imgVersions []string
+	var err error
+	if r.isDockerHub {
+		imgVersions, err = r.getDockerHubTags(imageName)
+	} else {
+		imgVersions, err = r.getOCITags(imageName)
+	}
 	if err != nil {
 		return nil, err
 	}
-	req.Header = r.httpHeader
-	resp, err := r.client.Do(req)
+
+	if len(imgVersions) < 1 {
+		return nil, fmt.Errorf("could not find image versions for '%v'", imageName)
+	}
+
+	return imgVersions, nil
+}
+
+// GetImageDigest returns the digest of the manifest (or manifest list) the
+// given tag of the image points to.
+func (r *Registry) GetImageDigest(imageName string, tag string) (string, error) {
+	if r.isDockerHub {
+		return r.getDockerHubDigest(imageName, tag)
+	}
+	return r.getOCIDigest(imageName, tag)
+}
+
+// get sends a GET request to the given URL and returns the response together
+// with the read body. Responses other than 200 OK are returned as error.
+func (r *Registry) get(reqURL string, imageName string) (*http.Response, []byte, error) {
+	return r.request("GET", reqURL, imageName, nil)
+}
+
+// request sends a request to the given URL and returns the response together
+// with the read body. The given header values are added to the default
+// header. Responses other than 200 OK are returned as error.
+//
+// If the registry answers with a bearer token challenge, a token for the
+// pull scope of the image is requested and the request is retried. Tokens are
+// cached per scope until they expire.
+func (r *Registry) request(method string, reqURL string, imageName string, header http.Header) (*http.Response, []byte, error) {
+	err := r.resolveCredentials()
+	if err != nil {
+		return nil, nil, err
+	}
+	scope := "repository:" + imageName + ":pull"
+	resp, err := r.send(method, reqURL, header, r.tokens.get(scope, r.now()))
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		challenge, challengeErr := parseChallenge(resp.Header.Get("WWW-Authenticate"))
+		if challengeErr == nil && challenge.scheme == "bearer" {
+			resp.Body.Close()
+			bearerToken, err := r.fetchToken(challenge, scope)
+			if err != nil {
+				return nil, nil, err
+			}
+			resp, err = r.send(method, reqURL, header, bearerToken)
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("registry http error for '%v': %v", imageName, resp.Status)
+		return nil, nil, fmt.Errorf("registry http error for '%v': %v", imageName, resp.Status)
 	}
 	bodyBytes, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
+	return resp, bodyBytes, nil
+}
 
-	var tagRes tagResponse
-	err = json.Unmarshal(bodyBytes, &tagRes)
+func (r *Registry) send(method string, reqURL string, header http.Header, bearerToken string) (*http.Response, error) {
+	req, err := http.NewRequest(method, reqURL, nil)
 	if err != nil {
 		return nil, err
 	}
+	req.Header = r.httpHeader.Clone()
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	if bearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+bearerToken)
+	} else if r.user != "" && r.password != "" {
+		req.SetBasicAuth(r.user, r.password)
+	}
+	return r.client.Do(req)
+}
 
-	var imgVersions []string
-	for _, t := range tagRes.Results {
-		imgVersions = append(imgVersions, t.Name)
+// resolveCredentials looks up the credentials for the registry in the Docker
+// config once. Credential helpers are only invoked when the registry is
+// actually queried. Explicitly given credentials take precedence over the
+// Docker config.
+func (r *Registry) resolveCredentials() error {
+	if r.explicitCreds {
+		return nil
 	}
+	r.credsOnce.Do(func() {
+		var cfg *dockerConfig
+		cfg, r.credsErr = loadDockerConfig(r.dockerCfg)
+		if r.credsErr != nil {
+			return
+		}
+		r.user, r.password, r.credsErr = cfg.credentials(r.registry)
+	})
+	return r.credsErr
+}
 
-	if len(imgVersions) < 1 {
-		return nil, fmt.Errorf("could not find image versions for '%v'", imageName)
+func (r *Registry) exceedsMaxPages(page int) bool {
+	return r.maxPages > 0 && page > r.maxPages
+}
+
+func normalizeURL(registryURL string) string {
+	if !strings.Contains(registryURL, "://") {
+		registryURL = "https://" + registryURL
 	}
+	return strings.TrimSuffix(registryURL, "/")
+}
 
-	return imgVersions, nil
+func isDockerHub(registryURL string) bool {
+	u, err := url.Parse(registryURL)
+	if err != nil {
+		return false
+	}
+	return u.Host == "hub.docker.com"
 }
@@ -21,7 +21,7 @@ func (c *httpClientMock) Do(req *http.Request) (*http.Response, error) {
 		StatusCode: http.StatusOK,
 		Body: io.NopCloser(strings.NewReader(`{
 	"count": 25,
-	"next": "https://examplecom/v2/repositories/some/image/tags?page=2",
+	"next": null,
 	"previous": null,
 	"results": [
 		{
@@ -173,3 +173,82 @@ func TestGetImageVersions_noVersionsFound(t *testing.T) {
 		t.Error("expected error, got nil")
 	}
 }
+
+func TestNewRegistry_isDockerHub(t *testing.T) {
+	tests := []struct {
+		name     string
+		registry string
+		expected bool
+	}{
+		{
+			"default registry",
+			"",
+			true,
+		},
+		{
+			"Docker Hub",
+			"https://hub.docker.com",
+			true,
+		},
+		{
+			"Docker Hub without scheme",
+			"hub.docker.com/",
+			true,
+		},
+		{
+			"other registry",
+			"https://ghcr.io",
+			false,
+		},
+		{
+			"other registry with port",
+			"registry.example.com:5000",
+			false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRegistry(&Config{Registry: tt.registry})
+			if tt.expected != r.isDockerHub {
+				t.Errorf("expected '%v', got '%v'", tt.expected, r.isDockerHub)
+			}
+		})
+	}
+}
+
+func TestNormalizeURL(t *testing.T) {
+	tests := []struct {
+		name        string
+		registryURL string
+		expected    string
+	}{
+		{
+			"full URL",
+			"https://registry.example.com",
+			"https://registry.example.com",
+		},
+		{
+			"trailing slash",
+			"https://registry.example.com/",
+			"https://registry.example.com",
+		},
+		{
+			"no scheme",
+			"registry.example.com:5000",
+			"https://registry.example.com:5000",
+		},
+		{
+			"http scheme",
+			"http://localhost:5000",
+			"http://localhost:5000",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual := normalizeURL(tt.registryURL)
+			if tt.expected != actual {
+				t.Errorf("expected '%v', got '%v'", tt.expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package registry
+
+import (
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// Router dispatches the version lookups of images to the registry named in the
+// image reference, e.g. 'ghcr.io/org/app' is looked up on 'https://ghcr.io'.
+// Images without a registry host or with the host of the configured default
+// registry are looked up on the default registry, so that they use its
+// credentials.
+type Router struct {
+	dockerConfig    string
+	maxPages        int
+	defaultRegistry *Registry
+	defaultHost     string
+	newRegistry     func(cfg *Config) *Registry
+	mu              sync.Mutex
+	registries      map[string]*Registry
+}
+
+func NewRouter(cfg *Config) *Router {
+	defaultRegistry := NewRegistry(cfg)
+	return &Router{
+		dockerConfig:    cfg.DockerConfig,
+		maxPages:        cfg.MaxPages,
+		defaultRegistry: defaultRegistry,
+		defaultHost:     urlHost(defaultRegistry.registry),
+		newRegistry:     NewRegistry,
+		registries:      map[string]*Registry{},
+	}
+}
+
+func (r *Router) GetImageVersions(imageName string) ([]string, error) {
+	reg, name := r.route(imageName)
+	return reg.GetImageVersions(name)
+}
+
+func (r *Router) GetImageDigest(imageName string, tag string) (string, error) {
+	reg, name := r.route(imageName)
+	return reg.GetImageDigest(name, tag)
+}
+
+// route returns the registry for the given image name together with the image
+// name without the registry host.
+func (r *Router) route(imageName string) (*Registry, string) {
+	domain, remainder := splitDomain(imageName)
+	reg := r.defaultRegistry
+	if domain != "" {
+		reg = r.registryFor(domain)
+	}
+	// official images are only prefixed on Docker Hub, other registries
+	// have no such convention
+	if reg.isDockerHub && !strings.Contains(remainder, "/") {
+		remainder = "library/" + remainder
+	}
+	return reg, remainder
+}
+
+// registryFor returns the registry for the given host. The default registry
+// is returned for its own host, other registries are created on first use.
+func (r *Router) registryFor(domain string) *Registry {
+	regURL := registryURL(domain)
+	if urlHost(regURL) == r.defaultHost {
+		return r.defaultRegistry
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	reg, ok := r.registries[domain]
+	if !ok {
+		reg = r.newRegistry(&Config{
+			Registry:     regURL,
+			DockerConfig: r.dockerConfig,
+			MaxPages:     r.maxPages,
+		})
+		r.registries[domain] = reg
+	}
+	return reg
+}
+
+// splitDomain splits the registry host off the image name. Following the
+// Docker reference grammar the first path component is only a host if it
+// contains a '.' or ':' or is 'localhost'.
+func splitDomain(imageName string) (domain string, remainder string) {
+	domain, remainder, found := strings.Cut(imageName, "/")
+	if !found || !strings.ContainsAny(domain, ".:") && domain != "localhost" {
+		return "", imageName
+	}
+	return domain, remainder
+}
+
+// registryURL returns the URL of the registry API for the given host. Docker
+// Hub references are mapped to the Docker Hub API and local registries are
+// expected to be served via plain HTTP.
+func registryURL(domain string) string {
+	if isDockerHubHost(domain) {
+		return dockerHubURL
+	}
+	host, _, _ := strings.Cut(domain, ":")
+	if host == "localhost" || host == "127.0.0.1" {
+		return "http://" + domain
+	}
+	return "https://" + domain
+}
+
+// urlHost returns the host (and port) of the registry URL.
+func urlHost(registryURL string) string {
+	u, err := url.Parse(registryURL)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
@@ -0,0 +1,203 @@
+package registry
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRouter_GetImageVersions(t *testing.T) {
+	tests := []struct {
+		name        string
+		imageName   string
+		expectedURL string
+	}{
+		{
+			"image without registry host",
+			"library/alpine",
+			"https://registry.example.com/v2/library/alpine/tags/list?n=1000",
+		},
+		{
+			"single-segment image without registry host",
+			"myapp",
+			"https://registry.example.com/v2/myapp/tags/list?n=1000",
+		},
+		{
+			"image with registry host",
+			"ghcr.io/org/app",
+			"https://ghcr.io/v2/org/app/tags/list?n=1000",
+		},
+		{
+			"image with registry host and port",
+			"registry.example.com:5000/team/api",
+			"https://registry.example.com:5000/v2/team/api/tags/list?n=1000",
+		},
+		{
+			"image on local registry",
+			"localhost:5000/app",
+			"http://localhost:5000/v2/app/tags/list?n=1000",
+		},
+		{
+			"image on Docker Hub",
+			"docker.io/alpine",
+			"https://hub.docker.com/v2/repositories/library/alpine/tags/?ordering=last_updated&page=1&page_size=100",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var reqURL string
+			client := &httpClientMock{
+				doFunc: func(req *http.Request) (*http.Response, error) {
+					reqURL = req.URL.String()
+					return &http.Response{
+						StatusCode: http.StatusOK,
+						Body:       io.NopCloser(strings.NewReader(`{"tags": ["1.0.0"], "results": [{"name": "1.0.0"}]}`)),
+					}, nil
+				},
+			}
+			r := NewRouter(&Config{Registry: "https://registry.example.com"})
+			r.defaultRegistry.client = client
+			r.newRegistry = func(cfg *Config) *Registry {
+				reg := NewRegistry(cfg)
+				reg.client = client
+				return reg
+			}
+			_, err := r.GetImageVersions(tt.imageName)
+			if err != nil {
+				t.Fatalf("expected no error, got '%v'", err)
+			}
+			if tt.expectedURL != reqURL {
+				t.Errorf("expected request to '%v', got '%v'", tt.expectedURL, reqURL)
+			}
+		})
+	}
+}
+
+func TestRouter_GetImageDigest(t *testing.T) {
+	var reqURL string
+	r := NewRouter(&Config{Registry: "https://registry.example.com"})
+	r.newRegistry = func(cfg *Config) *Registry {
+		reg := NewRegistry(cfg)
+		reg.client = &httpClientMock{
+			doFunc: func(req *http.Request) (*http.Response, error) {
+				reqURL = req.URL.String()
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Header: http.Header{
+						"Docker-Content-Digest": []string{"sha256:abc"},
+					},
+					Body: io.NopCloser(strings.NewReader("")),
+				}, nil
+			},
+		}
+		return reg
+	}
+	actual, err := r.GetImageDigest("ghcr.io/org/app", "1.0.0")
+	if err != nil {
+		t.Fatalf("expected no error, got '%v'", err)
+	}
+	expectedURL := "https://ghcr.io/v2/org/app/manifests/1.0.0"
+	if expectedURL != reqURL {
+		t.Errorf("expected request to '%v', got '%v'", expectedURL, reqURL)
+	}
+	if actual != "sha256:abc" {
+		t.Errorf("expected '%v', got '%v'", "sha256:abc", actual)
+	}
+}
+
+func TestRouter_reusesRegistry(t *testing.T) {
+	r := NewRouter(&Config{})
+	created := 0
+	r.newRegistry = func(cfg *Config) *Registry {
+		created++
+		return NewRegistry(cfg)
+	}
+	r.route("ghcr.io/org/app-1")
+	r.route("ghcr.io/org/app-2")
+	r.route("quay.io/org/app")
+	if created != 2 {
+		t.Errorf("expected 2 registries to be created, got %d", created)
+	}
+}
+
+func TestRouter_credentialsOnlyForDefaultRegistry(t *testing.T) {
+	r := NewRouter(&Config{User: "user", Password: "password"})
+	reg, _ := r.route("ghcr.io/org/app")
+	if reg.user != "" || reg.password != "" {
+		t.Error("expected no credentials for other registries")
+	}
+	reg, _ = r.route("library/alpine")
+	if reg.user != "user" || reg.password != "password" {
+		t.Error("expected credentials for the default registry")
+	}
+}
+
+func TestRouter_defaultRegistryHost(t *testing.T) {
+	r := NewRouter(&Config{Registry: "https://registry.example.com", User: "ci", Password: "pw"})
+	reg, name := r.route("registry.example.com/team/api")
+	if reg != r.defaultRegistry {
+		t.Error("expected the default registry for its own host")
+	}
+	if reg.user != "ci" || reg.password != "pw" {
+		t.Error("expected the credentials of the default registry")
+	}
+	if name != "team/api" {
+		t.Errorf("expected '%v', got '%v'", "team/api", name)
+	}
+
+	r = NewRouter(&Config{User: "user", Password: "password"})
+	reg, name = r.route("docker.io/alpine")
+	if reg != r.defaultRegistry {
+		t.Error("expected the default registry for Docker Hub references")
+	}
+	if name != "library/alpine" {
+		t.Errorf("expected '%v', got '%v'", "library/alpine", name)
+	}
+}
+
+func TestRouter_officialImages(t *testing.T) {
+	tests := []struct {
+		registry     string
+		imageName    string
+		expectedName string
+	}{
+		{"", "alpine", "library/alpine"},
+		{"https://registry.example.com", "alpine", "alpine"},
+		{"https://registry.example.com", "docker.io/alpine", "library/alpine"},
+		{"https://registry.example.com", "registry.example.com/alpine", "alpine"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.registry+" "+tt.imageName, func(t *testing.T) {
+			r := NewRouter(&Config{Registry: tt.registry})
+			_, name := r.route(tt.imageName)
+			if name != tt.expectedName {
+				t.Errorf("expected '%v', got '%v'", tt.expectedName, name)
+			}
+		})
+	}
+}
+
+func TestSplitDomain(t *testing.T) {
+	tests := []struct {
+		imageName         string
+		expectedDomain    string
+		expectedRemainder string
+	}{
+		{"alpine", "", "alpine"},
+		{"library/alpine", "", "library/alpine"},
+		{"ghcr.io/org/app", "ghcr.io", "org/app"},
+		{"registry.example.com:5000/team/api", "registry.example.com:5000", "team/api"},
+		{"localhost/app", "localhost", "app"},
+		{"registry:5000/app", "registry:5000", "app"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.imageName, func(t *testing.T) {
+			domain, remainder := splitDomain(tt.imageName)
+			if tt.expectedDomain != domain || tt.expectedRemainder != remainder {
+				t.Errorf("expected '%v' and '%v', got '%v' and '%v'",
+					tt.expectedDomain, tt.expectedRemainder, domain, remainder)
+			}
+		})
+	}
+}